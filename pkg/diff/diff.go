@@ -0,0 +1,330 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	// Modules
+	sqobj "github.com/mutablelogic/go-sqlite/pkg/sqobj"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/quote"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Schema is a set of table and index definitions which can be compared
+// with another schema
+type Schema struct {
+	name   string
+	tables []*table
+}
+
+// table is a table definition with its indexes and triggers
+type table struct {
+	SQTable
+	indexes  []SQIndexView
+	triggers []SQTrigger
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultSchema   = "main"
+	rebuildSuffix   = "_new"
+	maxRebuildIndex = 100
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// FromTransaction returns the tables, indexes and triggers in a schema of
+// a database. Virtual tables are not included.
+func FromTransaction(txn SQTransaction, schema string) (*Schema, error) {
+	if schema == "" {
+		schema = defaultSchema
+	}
+	result := &Schema{name: schema}
+	for _, name := range txn.Tables(schema) {
+		def := txn.TableDefinition(schema, name)
+		if def == nil {
+			continue
+		}
+		t := &table{def, nil, txn.TriggersForTable(schema, name)}
+		for _, index := range txn.IndexesForTable(schema, name) {
+			if !index.Auto() {
+				t.indexes = append(t.indexes, index)
+			}
+		}
+		result.tables = append(result.tables, t)
+	}
+	return result, nil
+}
+
+// FromClasses returns the tables and indexes for a set of classes, as they
+// would be created in a schema. Classes should be in the order they are
+// created, so that parent tables are created before child tables.
+func FromClasses(schema string, classes ...*sqobj.Class) (*Schema, error) {
+	if schema == "" {
+		schema = defaultSchema
+	}
+	result := &Schema{name: schema}
+	for _, class := range classes {
+		if class == nil {
+			return nil, ErrBadParameter.With("FromClasses")
+		}
+		st := class.Table(N(class.Name()).WithSchema(schema), false)
+		if len(st) == 0 {
+			return nil, ErrBadParameter.Withf("FromClasses: %q", class.Name())
+		}
+		def, ok := st[0].(SQTable)
+		if !ok {
+			return nil, ErrInternalAppError.Withf("FromClasses: %q", class.Name())
+		}
+		t := &table{def, nil, nil}
+		for _, st := range st[1:] {
+			if index, ok := st.(SQIndexView); ok {
+				t.indexes = append(t.indexes, index)
+			}
+		}
+		result.tables = append(result.tables, t)
+	}
+	return result, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (s *Schema) String() string {
+	str := "<schema"
+	str += fmt.Sprintf(" name=%q", s.name)
+	for _, t := range s.tables {
+		str += fmt.Sprintf(" table=%q", t.Name())
+	}
+	return str + ">"
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Name returns the name of the schema
+func (s *Schema) Name() string {
+	return s.name
+}
+
+// Tables returns the names of the tables in the schema
+func (s *Schema) Tables() []string {
+	result := make([]string, len(s.tables))
+	for i, t := range s.tables {
+		result[i] = t.Name()
+	}
+	return result
+}
+
+// Diff returns the statements which converge the tables and indexes in
+// schema "from" to those in schema "to". The statements are qualified with
+// the name of schema "from", and should be executed in order in a single
+// transaction with foreign key constraints disabled. Tables and indexes which
+// are not in schema "to" are only dropped when the drop argument is true.
+//
+// Columns which are added to the end of a table are added with ALTER TABLE
+// where possible. Any other change to a table, such as changing a column or
+// a constraint, rebuilds the table by creating a new table, copying the
+// rows of common columns, dropping the existing table and renaming the new
+// one. Indexes and triggers on a rebuilt table are re-created, and views and
+// triggers on other tables which refer to it are kept.
+func Diff(from, to *Schema, drop bool) []SQStatement {
+	var drops, creates, alters, indexes, removes []SQStatement
+
+	for _, target := range to.tables {
+		def := target.SQTable.WithSchema(from.name)
+		source := from.table(target.Name())
+
+		// Create new tables
+		if source == nil {
+			creates = append(creates, def)
+			for _, index := range target.indexes {
+				indexes = append(indexes, indexFor(from.name, index))
+			}
+			continue
+		}
+
+		// Alter or rebuild existing tables
+		if st, ok := alterTable(source.SQTable, def); ok {
+			alters = append(alters, st...)
+		} else {
+			alters = append(alters, rebuildTable(from, source, def)...)
+			for _, index := range target.indexes {
+				indexes = append(indexes, indexFor(from.name, index))
+			}
+			for _, trigger := range source.triggers {
+				indexes = append(indexes, trigger)
+			}
+			continue
+		}
+
+		// Drop and create changed indexes
+		for _, index := range source.indexes {
+			if other := target.index(index.Name()); other == nil {
+				if drop {
+					drops = append(drops, N(index.Name()).WithSchema(from.name).DropIndex())
+				}
+			} else if !equalIndex(index, other) {
+				drops = append(drops, N(index.Name()).WithSchema(from.name).DropIndex())
+				indexes = append(indexes, indexFor(from.name, other))
+			}
+		}
+		for _, index := range target.indexes {
+			if source.index(index.Name()) == nil {
+				indexes = append(indexes, indexFor(from.name, index))
+			}
+		}
+	}
+
+	// Drop tables which are not in schema "to"
+	if drop {
+		for _, source := range from.tables {
+			if to.table(source.Name()) == nil {
+				removes = append(removes, N(source.Name()).WithSchema(from.name).DropTable())
+			}
+		}
+	}
+
+	// Return statements in order
+	result := make([]SQStatement, 0, len(drops)+len(creates)+len(alters)+len(indexes)+len(removes))
+	result = append(result, drops...)
+	result = append(result, creates...)
+	result = append(result, alters...)
+	result = append(result, indexes...)
+	return append(result, removes...)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// table returns a table by name, or nil
+func (s *Schema) table(name string) *table {
+	for _, t := range s.tables {
+		if strings.EqualFold(t.Name(), name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// index returns an index on a table by name, or nil
+func (t *table) index(name string) SQIndexView {
+	for _, index := range t.indexes {
+		if strings.EqualFold(index.Name(), name) {
+			return index
+		}
+	}
+	return nil
+}
+
+// indexFor returns an index definition in a schema
+func indexFor(schema string, index SQIndexView) SQStatement {
+	st := N(index.Name()).WithSchema(schema).CreateIndex(index.Table(), index.Columns()...)
+	if index.Unique() {
+		st = st.WithUnique()
+	}
+	return st
+}
+
+// equalIndex returns true if two indexes are on the same table and columns
+func equalIndex(a, b SQIndexView) bool {
+	return a.Unique() == b.Unique() && strings.EqualFold(a.Table(), b.Table()) && strings.Join(a.Columns(), ",") == strings.Join(b.Columns(), ",")
+}
+
+// alterTable returns the statements to alter table "from" to table "to",
+// and false if the table needs to be rebuilt. No statements are returned
+// if the tables are the same.
+func alterTable(from, to SQTable) ([]SQStatement, bool) {
+	a, b := from.Columns(), to.Columns()
+	if len(b) < len(a) {
+		return nil, false
+	}
+
+	// Existing columns should not change
+	for i := range a {
+		if fmt.Sprint(a[i]) != fmt.Sprint(b[i]) || a[i].Primary() != b[i].Primary() {
+			return nil, false
+		}
+	}
+
+	// Constraints and options should not change
+	if from.WithColumn(b[len(a):]...).Query() != to.Query() {
+		return nil, false
+	}
+
+	// Add columns
+	result := []SQStatement{}
+	for _, col := range b[len(a):] {
+		if !canAddColumn(col) {
+			return nil, false
+		}
+		result = append(result, N(to.Name()).WithSchema(to.Schema()).AlterTable().AddColumn(col))
+	}
+	return result, true
+}
+
+// canAddColumn returns true if a column can be added with ALTER TABLE
+func canAddColumn(col SQColumn) bool {
+	if col.Primary() != "" || col.Generated() == "STORED" {
+		return false
+	}
+	def := strings.ToUpper(strings.TrimSpace(fmt.Sprint(col.Default())))
+	if !col.Nullable() && (col.Default() == nil || def == "NULL") {
+		return false
+	}
+	if strings.HasPrefix(def, "CURRENT_") || strings.HasPrefix(def, "(") {
+		return false
+	}
+	return true
+}
+
+// rebuildTable returns the statements to rebuild a table with a new
+// definition, copying the rows of columns which are in both tables
+func rebuildTable(schema *Schema, from *table, to SQTable) []SQStatement {
+	// Choose a name for the new table which is not in use
+	name := to.Name() + rebuildSuffix
+	for i := 1; schema.table(name) != nil && i < maxRebuildIndex; i++ {
+		name = fmt.Sprint(to.Name(), rebuildSuffix, i)
+	}
+	source := N(from.Name()).WithSchema(schema.name)
+	target := N(name).WithSchema(schema.name)
+
+	// Determine the columns to copy, which cannot include generated columns
+	columns := []string{}
+	for _, col := range to.Columns() {
+		if col.Generated() != "" {
+			continue
+		}
+		for _, other := range from.Columns() {
+			if strings.EqualFold(col.Name(), other.Name()) && other.Generated() == "" {
+				columns = append(columns, col.Name())
+				break
+			}
+		}
+	}
+
+	// Create the new table, copy the rows, drop the existing table and rename the new one.
+	// The table is renamed with legacy_alter_table enabled, so that views and triggers
+	// which refer to the dropped table are not checked, and then refer to the new one
+	result := []SQStatement{to.WithName(name)}
+	if len(columns) > 0 {
+		cols := QuoteIdentifiers(columns...)
+		result = append(result, Q("INSERT INTO ", target, " (", cols, ") SELECT ", cols, " FROM ", source))
+	}
+	return append(result,
+		source.DropTable(),
+		Q("PRAGMA legacy_alter_table=ON"),
+		Q("ALTER TABLE ", target, " RENAME TO ", QuoteIdentifier(to.Name())),
+		Q("PRAGMA legacy_alter_table=OFF"),
+	)
+}
@@ -0,0 +1,159 @@
+package diff_test
+
+import (
+	"context"
+	"testing"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+	sqobj "github.com/mutablelogic/go-sqlite/pkg/sqobj"
+
+	// Namespace imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/diff"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+)
+
+type TestDiffA struct {
+	Key  string `sqlite:"key,primary"`
+	Name string `sqlite:"name"`
+}
+
+type TestDiffB struct {
+	Key  string `sqlite:"key,primary"`
+	Name string `sqlite:"name,index:name"`
+	Size int64  `sqlite:"size"`
+}
+
+type TestDiffC struct {
+	Key  string `sqlite:"key,primary"`
+	Name int64  `sqlite:"name"`
+}
+
+func diff(t *testing.T, conn SQConnection, drop bool, classes ...*sqobj.Class) []SQStatement {
+	var result []SQStatement
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		from, err := FromTransaction(txn, "main")
+		if err != nil {
+			return err
+		}
+		to, err := FromClasses("main", classes...)
+		if err != nil {
+			return err
+		}
+		result = Diff(from, to, drop)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func apply(t *testing.T, conn SQConnection, st []SQStatement) {
+	if err := conn.Do(context.Background(), SQLITE_TXN_NO_FOREIGNKEY_CONSTRAINTS, func(txn SQTransaction) error {
+		for _, st := range st {
+			if _, err := txn.Query(st); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Diff_001(t *testing.T) {
+	conn, err := sqlite3.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Create a table from the class
+	a := sqobj.MustRegisterClass(N("test"), TestDiffA{})
+	st := diff(t, conn, false, a)
+	if len(st) != 1 {
+		t.Fatal("Unexpected statements", st)
+	}
+	apply(t, conn, st)
+	if _, err := conn.Query(Q("INSERT INTO test (key, name) VALUES ('a', 'b')")); err != nil {
+		t.Fatal(err)
+	}
+
+	// No changes
+	if st := diff(t, conn, false, a); len(st) != 0 {
+		t.Error("Unexpected statements", st)
+	}
+
+	// Add a column and an index
+	b := sqobj.MustRegisterClass(N("test"), TestDiffB{})
+	st = diff(t, conn, false, b)
+	if len(st) != 2 {
+		t.Fatal("Unexpected statements", st)
+	} else if q := st[0].Query(); q != `ALTER TABLE main.test ADD COLUMN size INTEGER` {
+		t.Errorf("Unexpected statement: %q", q)
+	} else if q := st[1].Query(); q != `CREATE INDEX main.test_name ON test (name)` {
+		t.Errorf("Unexpected statement: %q", q)
+	}
+	apply(t, conn, st)
+	if st := diff(t, conn, false, b); len(st) != 0 {
+		t.Error("Unexpected statements", st)
+	}
+
+	// Change the type of a column, which rebuilds the table and keeps the rows
+	c := sqobj.MustRegisterClass(N("test"), TestDiffC{})
+	st = diff(t, conn, false, c)
+	if len(st) != 6 {
+		t.Fatal("Unexpected statements", st)
+	}
+	apply(t, conn, st)
+	if st := diff(t, conn, false, c); len(st) != 0 {
+		t.Error("Unexpected statements", st)
+	}
+	if n := conn.Count("main", "test"); n != 1 {
+		t.Error("Unexpected count", n)
+	}
+	if indexes := conn.IndexesForTable("main", "test"); len(indexes) != 1 || !indexes[0].Auto() {
+		t.Error("Unexpected indexes", indexes)
+	}
+
+	// Drop tables which are not defined
+	if st := diff(t, conn, true); len(st) != 1 {
+		t.Error("Unexpected statements", st)
+	} else if q := st[0].Query(); q != `DROP TABLE main.test` {
+		t.Errorf("Unexpected statement: %q", q)
+	}
+}
+
+func Test_Diff_002(t *testing.T) {
+	conn, err := sqlite3.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Create a table with a view and a trigger on another table which refer to it
+	a := sqobj.MustRegisterClass(N("test"), TestDiffA{})
+	apply(t, conn, diff(t, conn, false, a))
+	apply(t, conn, []SQStatement{
+		Q("CREATE TABLE other (key TEXT)"),
+		Q("CREATE VIEW test_view AS SELECT key, name FROM test"),
+		Q("CREATE TRIGGER other_insert AFTER INSERT ON other BEGIN INSERT INTO test (key, name) VALUES (new.key, 'trigger'); END"),
+		Q("INSERT INTO test (key, name) VALUES ('a', 'b')"),
+	})
+
+	// Rebuild the table, keeping the view and trigger
+	c := sqobj.MustRegisterClass(N("test"), TestDiffC{})
+	apply(t, conn, diff(t, conn, false, c))
+	apply(t, conn, []SQStatement{
+		Q("INSERT INTO other (key) VALUES ('c')"),
+	})
+	r, err := conn.Query(Q("SELECT COUNT(*) FROM test_view"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if row := r.Next(); len(row) != 1 || row[0] != int64(2) {
+		t.Error("Unexpected row", row)
+	}
+}
@@ -156,7 +156,7 @@ func CreateSchema(ctx context.Context, conn SQConnection, schema string, tokeniz
 // Get indexes and count of documents for each index
 func ListIndexWithCount(ctx context.Context, conn SQConnection, schema string) (map[string]int64, error) {
 	results := make(map[string]int64)
-	if err := conn.Do(ctx, 0, func(txn SQTransaction) error {
+	if err := conn.Do(ctx, SQLITE_TXN_READONLY, func(txn SQTransaction) error {
 		s := Q("SELECT name,COUNT(*) AS count FROM ", N(fileTableName).WithSchema(schema), " GROUP BY name")
 		r, err := txn.Query(s)
 		if err != nil && err != io.EOF {
@@ -233,7 +233,7 @@ func Query(schema string, snippet bool) SQSelect {
 		N(fileTableName).WithSchema(schema),
 	).LeftJoin(Q(N(searchTableName), ".rowid=", N(fileTableName), ".rowid"))
 	// Set the snippet expression
-	snippetExpr := V("")
+	snippetExpr := Q("'' AS snippet")
 	if snippet {
 		snippetExpr = Q("SNIPPET(", searchTableName, ",-1, '<em>', '</em>', '...', 64) AS snippet")
 	}
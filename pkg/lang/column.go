@@ -19,6 +19,10 @@ type column struct {
 	primary       bool
 	autoincrement bool
 	def           SQExpr
+	collation     string
+	hidden        bool
+	generated     string
+	expr          SQExpr
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -33,7 +37,7 @@ const (
 
 // C defines a column name
 func C(name string) SQColumn {
-	return &column{source{name, "", "", false}, defaultColumnDecltype, false, false, false, nil}
+	return &column{source{name, "", "", false}, defaultColumnDecltype, false, false, false, nil, "", false, "", nil}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -61,8 +65,34 @@ func (this *column) Primary() string {
 	}
 }
 
+func (this *column) AutoIncrement() bool {
+	return this.autoincrement
+}
+
+func (this *column) Default() SQExpr {
+	return this.def
+}
+
+func (this *column) Collation() string {
+	return this.collation
+}
+
+func (this *column) Hidden() bool {
+	return this.hidden
+}
+
+func (this *column) Generated() string {
+	return this.generated
+}
+
+// Affinity returns the type affinity for the declared type, using the
+// rules defined in section 3.1 of https://www.sqlite.org/datatype3.html
+func (this *column) Affinity() string {
+	return affinity(this.decltype)
+}
+
 func (this *column) WithType(v string) SQColumn {
-	return &column{this.source, v, this.notnull, this.primary, this.autoincrement, this.def}
+	return &column{this.source, v, this.notnull, this.primary, this.autoincrement, this.def, this.collation, this.hidden, this.generated, this.expr}
 }
 
 func (this *column) WithAlias(v string) SQSource {
@@ -70,23 +100,39 @@ func (this *column) WithAlias(v string) SQSource {
 }
 
 func (this *column) NotNull() SQColumn {
-	return &column{this.source, this.decltype, true, this.primary, this.autoincrement, this.def}
+	return &column{this.source, this.decltype, true, this.primary, this.autoincrement, this.def, this.collation, this.hidden, this.generated, this.expr}
 }
 
 func (this *column) WithPrimary() SQColumn {
-	return &column{this.source, this.decltype, true, true, this.autoincrement, this.def}
+	return &column{this.source, this.decltype, true, true, this.autoincrement, this.def, this.collation, this.hidden, this.generated, this.expr}
 }
 
 func (this *column) WithAutoIncrement() SQColumn {
-	return &column{this.source, this.decltype, true, true, true, this.def}
+	return &column{this.source, this.decltype, true, true, true, this.def, this.collation, this.hidden, this.generated, this.expr}
 }
 
 func (this *column) WithDefault(v interface{}) SQColumn {
-	return &column{this.source, this.decltype, true, true, this.autoincrement, V(v)}
+	return &column{this.source, this.decltype, this.notnull, this.primary, this.autoincrement, V(v), this.collation, this.hidden, this.generated, this.expr}
 }
 
 func (this *column) WithDefaultNow() SQColumn {
-	return &column{this.source, this.decltype, true, true, this.autoincrement, V("CURRENT_TIMESTAMP")}
+	return &column{this.source, this.decltype, this.notnull, this.primary, this.autoincrement, Q("CURRENT_TIMESTAMP"), this.collation, this.hidden, this.generated, this.expr}
+}
+
+func (this *column) WithCollation(v string) SQColumn {
+	return &column{this.source, this.decltype, this.notnull, this.primary, this.autoincrement, this.def, v, this.hidden, this.generated, this.expr}
+}
+
+func (this *column) WithHidden() SQColumn {
+	return &column{this.source, this.decltype, this.notnull, this.primary, this.autoincrement, this.def, this.collation, true, this.generated, this.expr}
+}
+
+func (this *column) WithGenerated(expr SQExpr, stored bool) SQColumn {
+	generated := "VIRTUAL"
+	if stored {
+		generated = "STORED"
+	}
+	return &column{this.source, this.decltype, this.notnull, this.primary, this.autoincrement, this.def, this.collation, this.hidden, generated, expr}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -105,5 +151,11 @@ func (this *column) String() string {
 	if this.def != nil {
 		tokens = append(tokens, "DEFAULT", fmt.Sprint(this.def))
 	}
+	if this.collation != "" {
+		tokens = append(tokens, "COLLATE", QuoteIdentifier(this.collation))
+	}
+	if this.expr != nil {
+		tokens = append(tokens, "GENERATED ALWAYS AS ("+fmt.Sprint(this.expr)+")", this.generated)
+	}
 	return strings.Join(tokens, " ")
 }
@@ -18,6 +18,13 @@ func Test_Column_000(t *testing.T) {
 		{C("a").NotNull(), `a TEXT NOT NULL`},
 		{C("a").WithType("VARCHAR"), `a VARCHAR`},
 		{C("a").WithAlias("b"), `a AS b`},
+		{C("a").WithDefault(1), `a TEXT DEFAULT 1`},
+		{C("a").WithDefaultNow(), `a TEXT DEFAULT CURRENT_TIMESTAMP`},
+		{C("a").NotNull().WithDefault("b"), `a TEXT NOT NULL DEFAULT 'b'`},
+		{C("a").WithPrimary().WithDefaultNow(), `a TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP`},
+		{C("a").WithCollation("NOCASE"), `a TEXT COLLATE NOCASE`},
+		{C("a").WithType("INTEGER").WithGenerated(Q("b * 2"), true), `a INTEGER GENERATED ALWAYS AS (b * 2) STORED`},
+		{C("a").WithGenerated(Q("b"), false), `a TEXT GENERATED ALWAYS AS (b) VIRTUAL`},
 	}
 
 	for _, test := range tests {
@@ -25,4 +32,36 @@ func Test_Column_000(t *testing.T) {
 			t.Errorf("db.N = %v, wanted %v", v, test.String)
 		}
 	}
+
+	// A default value does not change the constraints on a column
+	for _, col := range []SQColumn{C("a").WithDefault(1), C("a").WithDefaultNow()} {
+		if !col.Nullable() || col.Primary() != "" {
+			t.Error("Unexpected constraints for", col)
+		}
+	}
+}
+
+func Test_Column_001(t *testing.T) {
+	// Check column type affinity
+	tests := []struct {
+		In       SQColumn
+		Affinity string
+	}{
+		{C("a").WithType("INT"), "INTEGER"},
+		{C("a").WithType("UNSIGNED BIG INT"), "INTEGER"},
+		{C("a").WithType("VARCHAR(255)"), "TEXT"},
+		{C("a").WithType("CLOB"), "TEXT"},
+		{C("a").WithType("BLOB"), "BLOB"},
+		{C("a").WithType(""), "BLOB"},
+		{C("a").WithType("DOUBLE PRECISION"), "REAL"},
+		{C("a").WithType("FLOAT"), "REAL"},
+		{C("a").WithType("DECIMAL(10,5)"), "NUMERIC"},
+		{C("a").WithType("TIMESTAMP"), "NUMERIC"},
+	}
+
+	for _, test := range tests {
+		if v := test.In.Affinity(); v != test.Affinity {
+			t.Errorf("Affinity(%q) = %v, wanted %v", test.In.Type(), v, test.Affinity)
+		}
+	}
 }
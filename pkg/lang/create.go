@@ -17,9 +17,11 @@ type createtable struct {
 	temporary    bool
 	ifnotexists  bool
 	withoutrowid bool
+	strict       bool
 	unique       []string
 	index        []string
 	foreignkeys  []string
+	checks       []string
 	columns      []SQColumn
 }
 
@@ -28,35 +30,64 @@ type createtable struct {
 
 // Create a new table with name and defined columns
 func (this *source) CreateTable(columns ...SQColumn) SQTable {
-	return &createtable{source{this.name, this.schema, "", false}, false, false, false, nil, nil, nil, columns}
+	return &createtable{source{this.name, this.schema, "", false}, false, false, false, false, nil, nil, nil, nil, columns}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // PROPERTIES
 
+// Return the columns of the table
+func (this *createtable) Columns() []SQColumn {
+	result := make([]SQColumn, len(this.columns))
+	for i := range this.columns {
+		result[i] = this.columns[i]
+	}
+	return result
+}
+
+func (this *createtable) WithName(name string) SQTable {
+	return &createtable{source{name, this.schema, "", false}, this.temporary, this.ifnotexists, this.withoutrowid, this.strict, this.unique, this.index, this.foreignkeys, this.checks, this.columns}
+}
+
+func (this *createtable) WithSchema(schema string) SQTable {
+	return &createtable{source{this.name, schema, "", false}, this.temporary, this.ifnotexists, this.withoutrowid, this.strict, this.unique, this.index, this.foreignkeys, this.checks, this.columns}
+}
+
+func (this *createtable) WithColumn(columns ...SQColumn) SQTable {
+	return &createtable{this.source, this.temporary, this.ifnotexists, this.withoutrowid, this.strict, this.unique, this.index, this.foreignkeys, this.checks, append(this.Columns(), columns...)}
+}
+
 func (this *createtable) IfNotExists() SQTable {
-	return &createtable{this.source, this.temporary, true, this.ifnotexists, this.unique, this.index, this.foreignkeys, this.columns}
+	return &createtable{this.source, this.temporary, true, this.withoutrowid, this.strict, this.unique, this.index, this.foreignkeys, this.checks, this.columns}
 }
 
 func (this *createtable) WithTemporary() SQTable {
-	return &createtable{this.source, true, this.ifnotexists, this.withoutrowid, this.unique, this.index, this.foreignkeys, this.columns}
+	return &createtable{this.source, true, this.ifnotexists, this.withoutrowid, this.strict, this.unique, this.index, this.foreignkeys, this.checks, this.columns}
 }
 
 func (this *createtable) WithoutRowID() SQTable {
-	return &createtable{this.source, this.temporary, this.ifnotexists, true, this.unique, this.index, this.foreignkeys, this.columns}
+	return &createtable{this.source, this.temporary, this.ifnotexists, true, this.strict, this.unique, this.index, this.foreignkeys, this.checks, this.columns}
+}
+
+func (this *createtable) WithStrict() SQTable {
+	return &createtable{this.source, this.temporary, this.ifnotexists, this.withoutrowid, true, this.unique, this.index, this.foreignkeys, this.checks, this.columns}
 }
 
 func (this *createtable) WithUnique(columns ...string) SQTable {
-	return &createtable{this.source, this.temporary, this.ifnotexists, this.withoutrowid, append(this.unique, QuoteIdentifiers(columns...)), this.index, this.foreignkeys, this.columns}
+	return &createtable{this.source, this.temporary, this.ifnotexists, this.withoutrowid, this.strict, append(this.unique, QuoteIdentifiers(columns...)), this.index, this.foreignkeys, this.checks, this.columns}
 }
 
 func (this *createtable) WithIndex(columns ...string) SQTable {
-	return &createtable{this.source, this.temporary, this.ifnotexists, this.withoutrowid, this.unique, append(this.index, QuoteIdentifiers(columns...)), this.foreignkeys, this.columns}
+	return &createtable{this.source, this.temporary, this.ifnotexists, this.withoutrowid, this.strict, this.unique, append(this.index, QuoteIdentifiers(columns...)), this.foreignkeys, this.checks, this.columns}
 }
 
 func (this *createtable) WithForeignKey(key SQForeignKey, columns ...string) SQTable {
-	return &createtable{this.source, this.temporary, this.ifnotexists, this.withoutrowid, this.unique, this.index,
-		append(this.foreignkeys, key.(*foreignkey).Query(columns...)), this.columns}
+	return &createtable{this.source, this.temporary, this.ifnotexists, this.withoutrowid, this.strict, this.unique, this.index,
+		append(this.foreignkeys, key.(*foreignkey).Query(columns...)), this.checks, this.columns}
+}
+
+func (this *createtable) WithCheck(expr SQExpr) SQTable {
+	return &createtable{this.source, this.temporary, this.ifnotexists, this.withoutrowid, this.strict, this.unique, this.index, this.foreignkeys, append(this.checks, fmt.Sprint(expr)), this.columns}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -99,6 +130,9 @@ func (this *createtable) Query() string {
 			columns = append(columns, "INDEX ("+key+")")
 		}
 		columns = append(columns, this.foreignkeys...)
+		for _, check := range this.checks {
+			columns = append(columns, "CHECK ("+check+")")
+		}
 	}
 
 	// Add keywords into the query
@@ -118,8 +152,15 @@ func (this *createtable) Query() string {
 	tokens = append(tokens, "("+strings.Join(columns, ",")+")")
 
 	// Final flags
+	options := []string{}
 	if this.withoutrowid {
-		tokens = append(tokens, "WITHOUT ROWID")
+		options = append(options, "WITHOUT ROWID")
+	}
+	if this.strict {
+		options = append(options, "STRICT")
+	}
+	if len(options) > 0 {
+		tokens = append(tokens, strings.Join(options, ","))
 	}
 
 	// Return the query
@@ -52,6 +52,12 @@ func Test_Create_001(t *testing.T) {
 		{N("foo").CreateTable(N("a").WithType("TEXT")).WithForeignKey(N("bar").ForeignKey(), "a"), `CREATE TABLE foo (a TEXT,FOREIGN KEY (a) REFERENCES bar)`},
 		{N("foo").CreateTable(N("a").WithType("TEXT")).WithForeignKey(N("bar").ForeignKey("x", "y"), "a"), `CREATE TABLE foo (a TEXT,FOREIGN KEY (a) REFERENCES bar (x,y))`},
 		{N("foo").CreateTable(N("a").WithType("TEXT")).WithForeignKey(N("bar").ForeignKey(), "a"), `CREATE TABLE foo (a TEXT,FOREIGN KEY (a) REFERENCES bar)`},
+		{N("foo").CreateTable(N("a").WithType("TEXT")).WithForeignKey(N("bar").ForeignKey("x").WithKeys("a")), `CREATE TABLE foo (a TEXT,FOREIGN KEY (a) REFERENCES bar (x))`},
+		{N("foo").CreateTable(N("a").WithType("TEXT")).WithCheck(Q("a <> ''")), `CREATE TABLE foo (a TEXT,CHECK (a <> ''))`},
+		{N("foo").CreateTable(N("a").WithType("TEXT")).WithStrict(), `CREATE TABLE foo (a TEXT) STRICT`},
+		{N("foo").CreateTable(N("a").WithType("TEXT")).WithoutRowID().WithStrict(), `CREATE TABLE foo (a TEXT) WITHOUT ROWID,STRICT`},
+		{N("foo").CreateTable(C("a")).WithColumn(C("b"), C("c")), `CREATE TABLE foo (a TEXT,b TEXT,c TEXT)`},
+		{N("foo").CreateTable(C("a")).WithName("bar").WithSchema("main"), `CREATE TABLE main.bar (a TEXT)`},
 	}
 
 	for _, test := range tests {
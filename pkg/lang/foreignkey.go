@@ -14,8 +14,10 @@ import (
 
 type foreignkey struct {
 	*source
-	columns    []string
-	constraint string
+	columns  []string
+	keys     []string
+	ondelete string
+	onupdate string
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -23,24 +25,57 @@ type foreignkey struct {
 
 // Create a foreign key
 func (this *source) ForeignKey(columns ...string) SQForeignKey {
-	return &foreignkey{&source{this.name, "", "", false}, columns, ""}
+	return &foreignkey{&source{this.name, "", "", false}, columns, nil, "", ""}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // PROPERTIES
 
+func (this *foreignkey) Table() string {
+	return this.source.name
+}
+
+func (this *foreignkey) Columns() []string {
+	return this.columns
+}
+
+func (this *foreignkey) Keys() []string {
+	return this.keys
+}
+
+func (this *foreignkey) WithKeys(keys ...string) SQForeignKey {
+	return &foreignkey{this.source, this.columns, keys, this.ondelete, this.onupdate}
+}
+
 func (this *foreignkey) OnDeleteCascade() SQForeignKey {
-	return &foreignkey{this.source, this.columns, "ON DELETE CASCADE"}
+	return this.OnDelete("CASCADE")
+}
+
+func (this *foreignkey) OnDelete(action string) SQForeignKey {
+	return &foreignkey{this.source, this.columns, this.keys, strings.ToUpper(action), this.onupdate}
+}
+
+func (this *foreignkey) OnUpdate(action string) SQForeignKey {
+	return &foreignkey{this.source, this.columns, this.keys, this.ondelete, strings.ToUpper(action)}
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // STRINGIFY
 
 func (this *foreignkey) String() string {
-	return this.Query("<col>", "<col>")
+	if len(this.keys) > 0 {
+		return this.Query()
+	} else {
+		return this.Query("<col>", "<col>")
+	}
 }
 
+// Query returns the foreign key constraint for the child key columns, or
+// the columns set with WithKeys if no columns are provided
 func (this *foreignkey) Query(columns ...string) string {
+	if len(columns) == 0 {
+		columns = this.keys
+	}
 	tokens := []string{"FOREIGN KEY (" + QuoteIdentifiers(columns...) + ")", "REFERENCES", fmt.Sprint(this.source)}
 
 	// Add columns
@@ -48,9 +83,12 @@ func (this *foreignkey) Query(columns ...string) string {
 		tokens = append(tokens, "("+QuoteIdentifiers(this.columns...)+")")
 	}
 
-	// Add constraint clause
-	if this.constraint != "" {
-		tokens = append(tokens, this.constraint)
+	// Add constraint clauses
+	if this.ondelete != "" {
+		tokens = append(tokens, "ON DELETE", this.ondelete)
+	}
+	if this.onupdate != "" {
+		tokens = append(tokens, "ON UPDATE", this.onupdate)
 	}
 
 	// Return the query
@@ -22,6 +22,7 @@ func Test_ForeignKey_000(t *testing.T) {
 		{N("index").ForeignKey(), `FOREIGN KEY (foo) REFERENCES "index"`},
 		{N("index").ForeignKey().OnDeleteCascade(), `FOREIGN KEY (foo) REFERENCES "index" ON DELETE CASCADE`},
 		{N("index").ForeignKey("a", "b").OnDeleteCascade(), `FOREIGN KEY (foo) REFERENCES "index" (a,b) ON DELETE CASCADE`},
+		{N("index").ForeignKey("a").OnDelete("set null").OnUpdate("restrict"), `FOREIGN KEY (foo) REFERENCES "index" (a) ON DELETE SET NULL ON UPDATE RESTRICT`},
 	}
 
 	for i, test := range tests {
@@ -17,6 +17,7 @@ type insert struct {
 	defaultvalues bool
 	columns       []string
 	conflicts     []conflict
+	rows          int
 }
 
 type conflict struct {
@@ -29,31 +30,40 @@ type conflict struct {
 
 // Insert values into a table with a name and defined column names
 func (this *source) Insert(columns ...string) SQInsert {
-	return &insert{source{this.name, this.schema, "", false}, "INSERT", false, columns, nil}
+	return &insert{source{this.name, this.schema, "", false}, "INSERT", false, columns, nil, 1}
 }
 
 // Replace values into a table with a name and defined column names
 func (this *source) Replace(columns ...string) SQInsert {
-	return &insert{source{this.name, this.schema, "", false}, "REPLACE", false, columns, nil}
+	return &insert{source{this.name, this.schema, "", false}, "REPLACE", false, columns, nil, 1}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // PROPERTIES
 
 func (this *insert) DefaultValues() SQInsert {
-	return &insert{this.source, this.class, true, this.columns, nil}
+	return &insert{this.source, this.class, true, this.columns, nil, this.rows}
 }
 
 // WithConflictUpdate sets the conflict resolution to do nothing (that is,
 // silently fail)
 func (this *insert) WithConflictDoNothing(target ...string) SQInsert {
-	return &insert{this.source, this.class, this.defaultvalues, this.columns, append(this.conflicts, conflict{"NOTHING", target})}
+	return &insert{this.source, this.class, this.defaultvalues, this.columns, append(this.conflicts, conflict{"NOTHING", target}), this.rows}
 }
 
 // WithConflictUpdate sets the conflict resolution to update the row only
 // when named columns are changed
 func (this *insert) WithConflictUpdate(target ...string) SQInsert {
-	return &insert{this.source, this.class, this.defaultvalues, this.columns, append(this.conflicts, conflict{"UPDATE SET", target})}
+	return &insert{this.source, this.class, this.defaultvalues, this.columns, append(this.conflicts, conflict{"UPDATE SET", target}), this.rows}
+}
+
+// WithRows sets the number of rows of values inserted by the statement,
+// with a parameter for each column in each row
+func (this *insert) WithRows(n int) SQInsert {
+	if n < 1 {
+		n = 1
+	}
+	return &insert{this.source, this.class, this.defaultvalues, this.columns, this.conflicts, n}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -78,7 +88,8 @@ func (this *insert) Query() string {
 	if this.defaultvalues || (len(this.columns) == 0) {
 		tokens = append(tokens, "DEFAULT VALUES")
 	} else if len(this.columns) > 0 {
-		tokens = append(tokens, "VALUES", this.argsN(len(this.columns)))
+		args := this.argsN(len(this.columns))
+		tokens = append(tokens, "VALUES", args+strings.Repeat(","+args, this.rows-1))
 	} else {
 		// No columns, return empty query
 		return ""
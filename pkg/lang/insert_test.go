@@ -21,6 +21,10 @@ func Test_Insert_000(t *testing.T) {
 		{N("foo").WithSchema("main").Replace(), `REPLACE INTO main.foo DEFAULT VALUES`},
 		{N("foo").WithSchema("main").Replace("a"), `REPLACE INTO main.foo (a) VALUES (?)`},
 		{N("foo").WithSchema("main").Replace("a", "b"), `REPLACE INTO main.foo (a,b) VALUES (?,?)`},
+		{N("foo").Insert("a").WithRows(0), `INSERT INTO foo (a) VALUES (?)`},
+		{N("foo").Insert("a").WithRows(3), `INSERT INTO foo (a) VALUES (?),(?),(?)`},
+		{N("foo").Insert("a", "b").WithRows(2), `INSERT INTO foo (a,b) VALUES (?,?),(?,?)`},
+		{N("foo").Insert().WithRows(2), `INSERT INTO foo DEFAULT VALUES`},
 	}
 
 	for _, test := range tests {
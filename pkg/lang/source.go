@@ -54,7 +54,7 @@ func (this *source) WithAlias(alias string) SQSource {
 }
 
 func (this *source) WithType(decltype string) SQColumn {
-	return &column{*this, decltype, false, false, false, nil}
+	return &column{*this, decltype, false, false, false, nil, "", false, "", nil}
 }
 
 func (this *source) WithDesc() SQSource {
@@ -1,6 +1,7 @@
 package lang
 
 import (
+	"fmt"
 	"strings"
 
 	// Import namespaces
@@ -18,6 +19,7 @@ type trigger struct {
 	table       string
 	when        string
 	action      string
+	expr        SQExpr
 	statements  []SQStatement
 }
 
@@ -29,7 +31,7 @@ func (this *source) CreateTrigger(table string, st ...SQStatement) SQTrigger {
 	if len(st) == 0 {
 		return nil
 	} else {
-		return &trigger{source{this.name, this.schema, "", false}, false, false, table, "AFTER", "INSERT", st}
+		return &trigger{source{this.name, this.schema, "", false}, false, false, table, "AFTER", "INSERT", nil, st}
 	}
 }
 
@@ -56,6 +58,11 @@ func (this *trigger) Query() string {
 	// Add source and action
 	tokens = append(tokens, this.source.Query(), this.when, this.action, "ON", quote.QuoteIdentifier(this.table))
 
+	// Add condition
+	if this.expr != nil {
+		tokens = append(tokens, "WHEN", fmt.Sprint(this.expr))
+	}
+
 	// Add Begin and End
 	tokens = append(tokens, "BEGIN")
 	for _, st := range this.statements {
@@ -117,7 +124,13 @@ func (this *trigger) Update(col ...string) SQTrigger {
 	if len(col) == 0 {
 		copy.action = "UPDATE"
 	} else {
-		copy.action = "UPDATE OF (" + quote.QuoteIdentifiers(col...) + ")"
+		copy.action = "UPDATE OF " + quote.QuoteIdentifiers(col...)
 	}
 	return &copy
 }
+
+func (this *trigger) When(expr SQExpr) SQTrigger {
+	copy := *this
+	copy.expr = expr
+	return &copy
+}
@@ -26,7 +26,8 @@ func Test_Trigger_000(t *testing.T) {
 		{N("a").CreateTrigger("b", Q("statement_a")).Before().Update(), `CREATE TRIGGER a BEFORE UPDATE ON b BEGIN statement_a; END`},
 		{N("a").CreateTrigger("b", Q("statement_a")).After().Update(), `CREATE TRIGGER a AFTER UPDATE ON b BEGIN statement_a; END`},
 		{N("a").CreateTrigger("b", Q("statement_a")).InsteadOf().Update(), `CREATE TRIGGER a INSTEAD OF UPDATE ON b BEGIN statement_a; END`},
-		{N("a").CreateTrigger("b", Q("statement_a")).Before().Update("x", "y"), `CREATE TRIGGER a BEFORE UPDATE OF (x,y) ON b BEGIN statement_a; END`},
+		{N("a").CreateTrigger("b", Q("statement_a")).Before().Update("x", "y"), `CREATE TRIGGER a BEFORE UPDATE OF x,y ON b BEGIN statement_a; END`},
+		{N("a").CreateTrigger("b", Q("statement_a")).When(Q("new.x > 0")), `CREATE TRIGGER a AFTER INSERT ON b WHEN new.x > 0 BEGIN statement_a; END`},
 		{N("a").WithSchema("s").CreateTrigger("b", Q("statement_a"), Q("statement_b")), `CREATE TRIGGER s.a AFTER INSERT ON b BEGIN statement_a; statement_b; END`},
 	}
 
@@ -26,3 +26,20 @@ func sliceJoin(slice interface{}, sep string, fn func(elem interface{}) string)
 	}
 	return strings.Join(result, sep)
 }
+
+// affinity returns the type affinity for a declared column type
+func affinity(decltype string) string {
+	decltype = strings.ToUpper(decltype)
+	switch {
+	case strings.Contains(decltype, "INT"):
+		return "INTEGER"
+	case strings.Contains(decltype, "CHAR"), strings.Contains(decltype, "CLOB"), strings.Contains(decltype, "TEXT"):
+		return "TEXT"
+	case decltype == "", strings.Contains(decltype, "BLOB"):
+		return "BLOB"
+	case strings.Contains(decltype, "REAL"), strings.Contains(decltype, "FLOA"), strings.Contains(decltype, "DOUB"):
+		return "REAL"
+	default:
+		return "NUMERIC"
+	}
+}
@@ -0,0 +1,430 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Migrator applies versioned migrations to a schema
+type Migrator struct {
+	sync.Mutex
+	schema string
+	table  string
+	steps  []*Step
+}
+
+// Step is a migration from the previous version to Version, and optionally
+// back again
+type Step struct {
+	Version int
+	Name    string
+	Up      StepFunc
+	Down    StepFunc
+}
+
+// StepFunc performs a migration step within a transaction
+type StepFunc func(context.Context, SQTransaction) error
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// errDryRun is returned to roll back a dry run
+	errDryRun = errors.New("dry run")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// New returns a migrator for a schema, which tracks the current version
+// using PRAGMA user_version. If schema is empty, the main schema is used.
+func New(schema string) *Migrator {
+	if schema == "" {
+		schema = defaultSchema
+	}
+	return &Migrator{schema: schema}
+}
+
+// WithTable tracks applied migrations in a table rather than
+// PRAGMA user_version, which leaves user_version free for the application.
+// The table is created when the first migration is applied.
+func (m *Migrator) WithTable(name string) *Migrator {
+	m.Lock()
+	defer m.Unlock()
+	m.table = name
+	return m
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (m *Migrator) String() string {
+	str := "<migrator"
+	str += fmt.Sprintf(" schema=%q", m.schema)
+	if m.table != "" {
+		str += fmt.Sprintf(" table=%q", m.table)
+	}
+	for _, step := range m.steps {
+		str += " " + step.String()
+	}
+	return str + ">"
+}
+
+func (s *Step) String() string {
+	str := "<step"
+	str += fmt.Sprint(" version=", s.Version)
+	if s.Name != "" {
+		str += fmt.Sprintf(" name=%q", s.Name)
+	}
+	if s.Down != nil {
+		str += " reversible"
+	}
+	return str + ">"
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Statements returns a step function which executes statements in order
+func Statements(st ...SQStatement) StepFunc {
+	return func(ctx context.Context, txn SQTransaction) error {
+		for _, st := range st {
+			if _, err := txn.Query(st); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Add registers a migration step for a version, which should be greater
+// than zero. The down function can be nil, in which case the step cannot be
+// reverted.
+func (m *Migrator) Add(version int, name string, up, down StepFunc) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if version <= 0 || up == nil {
+		return ErrBadParameter.Withf("Add: %d", version)
+	}
+	for _, step := range m.steps {
+		if step.Version == version {
+			return ErrDuplicateEntry.Withf("Add: %d", version)
+		}
+	}
+
+	// Keep steps in version order
+	m.steps = append(m.steps, &Step{version, name, up, down})
+	sort.Slice(m.steps, func(i, j int) bool {
+		return m.steps[i].Version < m.steps[j].Version
+	})
+
+	// Return success
+	return nil
+}
+
+// Steps returns the registered migration steps in version order
+func (m *Migrator) Steps() []*Step {
+	m.Lock()
+	defer m.Unlock()
+	return append([]*Step{}, m.steps...)
+}
+
+// Latest returns the version of the last registered step, or zero
+func (m *Migrator) Latest() int {
+	m.Lock()
+	defer m.Unlock()
+	if len(m.steps) == 0 {
+		return 0
+	}
+	return m.steps[len(m.steps)-1].Version
+}
+
+// Version returns the current version of the schema, or zero if no
+// migrations have been applied
+func (m *Migrator) Version(ctx context.Context, conn SQConnection) (int, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.versionFor(ctx, conn)
+}
+
+// Plan returns the steps which would be applied to migrate the schema to
+// a target version, in the order they would be applied. When the target
+// version is lower than the current version, the steps are reverted.
+func (m *Migrator) Plan(ctx context.Context, conn SQConnection, target int) ([]*Step, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	current, err := m.versionFor(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return m.plan(current, target)
+}
+
+// Up migrates the schema to the latest version, and returns the steps
+// applied
+func (m *Migrator) Up(ctx context.Context, conn SQConnection) ([]*Step, error) {
+	return m.Migrate(ctx, conn, m.Latest())
+}
+
+// Migrate migrates the schema up or down to a target version, and returns
+// the steps applied. Each step is applied in its own transaction, with
+// foreign key constraints disabled, so that tables can be rebuilt. If a step
+// fails, the steps already applied are not reverted.
+func (m *Migrator) Migrate(ctx context.Context, conn SQConnection, target int) ([]*Step, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	// Determine the steps to apply
+	current, err := m.versionFor(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := m.plan(current, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply each step in an immediate transaction. The version is read again
+	// within the transaction, in case another process has migrated the schema
+	// since the plan was made, and the step is skipped if it has been applied
+	result := make([]*Step, 0, len(plan))
+	for _, step := range plan {
+		applied := false
+		if err := conn.Do(ctx, SQLITE_TXN_IMMEDIATE|SQLITE_TXN_NO_FOREIGNKEY_CONSTRAINTS, func(txn SQTransaction) error {
+			current, err := m.version(txn)
+			if err != nil {
+				return err
+			}
+			if skip, err := m.check(step, target, current); err != nil || skip {
+				return err
+			}
+			applied = true
+			return m.apply(ctx, txn, step, target)
+		}); err != nil {
+			return result, err
+		} else if applied {
+			result = append(result, step)
+		}
+	}
+
+	// Return success
+	return result, nil
+}
+
+// DryRun applies the steps to migrate the schema to a target version in
+// a single transaction, which is then rolled back. It returns the steps which
+// would be applied, or the first error.
+func (m *Migrator) DryRun(ctx context.Context, conn SQConnection, target int) ([]*Step, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var result []*Step
+	if err := conn.Do(ctx, SQLITE_TXN_NO_FOREIGNKEY_CONSTRAINTS, func(txn SQTransaction) error {
+		current, err := m.version(txn)
+		if err != nil {
+			return err
+		}
+		plan, err := m.plan(current, target)
+		if err != nil {
+			return err
+		}
+		for _, step := range plan {
+			if err := txn.Do(ctx, 0, func(txn SQTransaction) error {
+				return m.apply(ctx, txn, step, target)
+			}); err != nil {
+				return err
+			}
+			result = append(result, step)
+		}
+		return errDryRun
+	}); !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	// Return success
+	return result, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// versionFor returns the current version of the schema in a new transaction
+func (m *Migrator) versionFor(ctx context.Context, conn SQConnection) (int, error) {
+	var version int
+	if err := conn.Do(ctx, SQLITE_TXN_DEFAULT, func(txn SQTransaction) error {
+		v, err := m.version(txn)
+		version = v
+		return err
+	}); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// version returns the current version of the schema
+func (m *Migrator) version(txn SQTransaction) (int, error) {
+	var st SQStatement
+	if m.table == "" {
+		st = Q("PRAGMA ", N(m.schema), ".user_version")
+	} else if !inList(txn.Tables(m.schema), m.table) {
+		return 0, nil
+	} else {
+		st = Q("SELECT MAX(version) FROM ", N(m.table).WithSchema(m.schema))
+	}
+	r, err := txn.Query(st)
+	if err != nil {
+		return 0, err
+	}
+	row := r.Next(intType)
+	if len(row) == 0 || row[0] == nil {
+		return 0, nil
+	}
+	return int(row[0].(int64)), nil
+}
+
+// plan returns the steps to move from the current version to the target
+// version
+func (m *Migrator) plan(current, target int) ([]*Step, error) {
+	if target < 0 {
+		return nil, ErrBadParameter.Withf("Migrate: %d", target)
+	}
+	if current != 0 && m.step(current) == nil {
+		return nil, ErrNotFound.Withf("Migrate: Unknown schema version %d", current)
+	}
+	if target != 0 && m.step(target) == nil {
+		return nil, ErrNotFound.Withf("Migrate: Unknown target version %d", target)
+	}
+
+	result := []*Step{}
+	if target >= current {
+		for _, step := range m.steps {
+			if step.Version > current && step.Version <= target {
+				result = append(result, step)
+			}
+		}
+	} else {
+		for i := len(m.steps) - 1; i >= 0; i-- {
+			step := m.steps[i]
+			if step.Version <= current && step.Version > target {
+				if step.Down == nil {
+					return nil, ErrNotImplemented.Withf("Migrate: Version %d cannot be reverted", step.Version)
+				}
+				result = append(result, step)
+			}
+		}
+	}
+
+	// Return success
+	return result, nil
+}
+
+// step returns a step for a version, or nil
+func (m *Migrator) step(version int) *Step {
+	for _, step := range m.steps {
+		if step.Version == version {
+			return step
+		}
+	}
+	return nil
+}
+
+// previous returns the version before a step, or zero
+func (m *Migrator) previous(step *Step) int {
+	version := 0
+	for _, s := range m.steps {
+		if s.Version < step.Version {
+			version = s.Version
+		}
+	}
+	return version
+}
+
+// check returns true if a step has already been applied towards the target
+// version, or an error if the current version is not the one the step
+// expects to migrate from
+func (m *Migrator) check(step *Step, target, current int) (bool, error) {
+	if step.Version <= target {
+		if current >= step.Version {
+			return true, nil
+		} else if current == m.previous(step) {
+			return false, nil
+		}
+	} else {
+		if current < step.Version {
+			return true, nil
+		} else if current == step.Version {
+			return false, nil
+		}
+	}
+	return false, ErrOutOfOrder.Withf("Migrate: Version %d: Schema version changed to %d", step.Version, current)
+}
+
+// apply runs a step up or down towards the target version, checks foreign
+// key constraints and records the new version
+func (m *Migrator) apply(ctx context.Context, txn SQTransaction, step *Step, target int) error {
+	up := step.Version <= target
+	if up {
+		if err := step.Up(ctx, txn); err != nil {
+			return fmt.Errorf("Migrate: Version %d: %w", step.Version, err)
+		}
+	} else if err := step.Down(ctx, txn); err != nil {
+		return fmt.Errorf("Migrate: Version %d: %w", step.Version, err)
+	}
+
+	// Check foreign key constraints, which are disabled during the step
+	if txn.Flags().Is(SQLITE_OPEN_FOREIGNKEYS) {
+		r, err := txn.Query(Q("PRAGMA ", N(m.schema), ".foreign_key_check"))
+		if err != nil {
+			return err
+		}
+		if row := r.Next(); row != nil {
+			return ErrInternalAppError.Withf("Migrate: Version %d: Foreign key constraint failed on %q", step.Version, row[0])
+		}
+	}
+
+	// Record the version
+	if up {
+		return m.setVersion(txn, step, step.Version)
+	} else {
+		return m.setVersion(txn, step, m.previous(step))
+	}
+}
+
+// setVersion records the version after a step has been applied or reverted
+func (m *Migrator) setVersion(txn SQTransaction, step *Step, version int) error {
+	if m.table == "" {
+		_, err := txn.Query(Q("PRAGMA ", N(m.schema), ".user_version=", version))
+		return err
+	}
+
+	// Create the table and record the step
+	table := N(m.table).WithSchema(m.schema)
+	if _, err := txn.Query(table.CreateTable(
+		C("version").WithType("INTEGER").WithPrimary(),
+		C("name").WithType("TEXT"),
+		C("applied").WithType("TIMESTAMP"),
+	).IfNotExists()); err != nil {
+		return err
+	}
+	if version == step.Version {
+		_, err := txn.Query(table.Insert("version", "name", "applied"), step.Version, step.Name, time.Now())
+		return err
+	} else {
+		_, err := txn.Query(table.Delete(Q("version=", step.Version)))
+		return err
+	}
+}
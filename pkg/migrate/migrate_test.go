@@ -0,0 +1,225 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+
+	// Namespace imports
+	. "github.com/djthorpe/go-errors"
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/migrate"
+)
+
+func newMigrator(t *testing.T, m *Migrator) *Migrator {
+	if err := m.Add(1, "create parent", Statements(
+		N("parent").CreateTable(C("a").WithType("INTEGER").WithPrimary()),
+	), Statements(
+		N("parent").DropTable(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(3, "insert parent", Statements(
+		Q("INSERT INTO parent (a) VALUES (1)"),
+	), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(2, "create child", Statements(
+		N("child").CreateTable(C("b").WithType("INTEGER")).WithForeignKey(N("parent").ForeignKey("a"), "b"),
+	), func(ctx context.Context, txn SQTransaction) error {
+		_, err := txn.Query(N("child").DropTable())
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func Test_Migrate_001(t *testing.T) {
+	conn, err := sqlite3.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Check steps are ordered, and versions are unique
+	m := newMigrator(t, New(""))
+	if steps := m.Steps(); len(steps) != 3 || steps[0].Version != 1 || steps[1].Version != 2 || steps[2].Version != 3 {
+		t.Error("Unexpected steps", steps)
+	}
+	if err := m.Add(2, "duplicate", Statements(), nil); err == nil {
+		t.Error("Expected error for duplicate version")
+	}
+
+	// Migrate to the latest version
+	if v, err := m.Version(context.Background(), conn); err != nil {
+		t.Error(err)
+	} else if v != 0 {
+		t.Error("Unexpected version", v)
+	}
+	if steps, err := m.Up(context.Background(), conn); err != nil {
+		t.Error(err)
+	} else if len(steps) != 3 {
+		t.Error("Unexpected steps", steps)
+	}
+	if v, err := m.Version(context.Background(), conn); err != nil {
+		t.Error(err)
+	} else if v != 3 {
+		t.Error("Unexpected version", v)
+	}
+
+	// Cannot revert step 3
+	if _, err := m.Migrate(context.Background(), conn, 1); err == nil {
+		t.Error("Expected error reverting an irreversible step")
+	}
+}
+
+func Test_Migrate_002(t *testing.T) {
+	conn, err := sqlite3.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Track versions in a table
+	m := newMigrator(t, New("main").WithTable("migrations"))
+	if steps, err := m.Migrate(context.Background(), conn, 2); err != nil {
+		t.Fatal(err)
+	} else if len(steps) != 2 {
+		t.Error("Unexpected steps", steps)
+	}
+	if v, err := m.Version(context.Background(), conn); err != nil {
+		t.Error(err)
+	} else if v != 2 {
+		t.Error("Unexpected version", v)
+	}
+	if v := conn.Count("main", "migrations"); v != 2 {
+		t.Error("Unexpected count", v)
+	}
+
+	// Dry run of a down migration does not change anything
+	if steps, err := m.DryRun(context.Background(), conn, 0); err != nil {
+		t.Error(err)
+	} else if len(steps) != 2 || steps[0].Version != 2 || steps[1].Version != 1 {
+		t.Error("Unexpected steps", steps)
+	}
+	if tables := conn.Tables("main"); len(tables) != 3 {
+		t.Error("Unexpected tables", tables)
+	}
+
+	// Down migration
+	if steps, err := m.Migrate(context.Background(), conn, 0); err != nil {
+		t.Error(err)
+	} else if len(steps) != 2 {
+		t.Error("Unexpected steps", steps)
+	}
+	if tables := conn.Tables("main"); len(tables) != 1 || tables[0] != "migrations" {
+		t.Error("Unexpected tables", tables)
+	}
+	if v, err := m.Version(context.Background(), conn); err != nil {
+		t.Error(err)
+	} else if v != 0 {
+		t.Error("Unexpected version", v)
+	}
+}
+
+func Test_Migrate_003(t *testing.T) {
+	conn, err := sqlite3.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A failing step is rolled back, and earlier steps are kept
+	m := newMigrator(t, New(""))
+	if err := m.Add(4, "fail", func(ctx context.Context, txn SQTransaction) error {
+		if _, err := txn.Query(Q("INSERT INTO parent (a) VALUES (2)")); err != nil {
+			return err
+		}
+		return errors.New("fail")
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if steps, err := m.Up(context.Background(), conn); err == nil {
+		t.Error("Expected error")
+	} else if len(steps) != 3 {
+		t.Error("Unexpected steps", steps)
+	}
+	if v := conn.Count("main", "parent"); v != 1 {
+		t.Error("Unexpected count", v)
+	}
+	if v, err := m.Version(context.Background(), conn); err != nil {
+		t.Error(err)
+	} else if v != 3 {
+		t.Error("Unexpected version", v)
+	}
+
+	// Plan returns the remaining step
+	if steps, err := m.Plan(context.Background(), conn, 4); err != nil {
+		t.Error(err)
+	} else if len(steps) != 1 || steps[0].Version != 4 {
+		t.Error("Unexpected steps", steps)
+	}
+}
+
+// racingConn migrates the schema to a target version with another migrator
+// before the first step is applied, as a concurrent process would
+type racingConn struct {
+	SQConnection
+	m      *Migrator
+	target int
+	done   bool
+}
+
+func (c *racingConn) Do(ctx context.Context, flag SQFlag, fn func(SQTransaction) error) error {
+	if !c.done && flag.Is(SQLITE_TXN_NO_FOREIGNKEY_CONSTRAINTS) {
+		c.done = true
+		if _, err := c.m.Migrate(ctx, c.SQConnection, c.target); err != nil {
+			return err
+		}
+	}
+	return c.SQConnection.Do(ctx, flag, fn)
+}
+
+func Test_Migrate_004(t *testing.T) {
+	conn, err := sqlite3.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Steps applied by another migrator after the plan is made are skipped
+	m := newMigrator(t, New(""))
+	if steps, err := m.Up(context.Background(), &racingConn{SQConnection: conn, m: newMigrator(t, New("")), target: 3}); err != nil {
+		t.Error(err)
+	} else if len(steps) != 0 {
+		t.Error("Unexpected steps", steps)
+	}
+	if v := conn.Count("main", "parent"); v != 1 {
+		t.Error("Unexpected count", v)
+	}
+
+	// When the schema is moved to an unexpected version, the migration is aborted
+	conn2, err := sqlite3.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	if _, err := m.Migrate(context.Background(), conn2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if steps, err := m.Up(context.Background(), &racingConn{SQConnection: conn2, m: newMigrator(t, New("")), target: 0}); !errors.Is(err, ErrOutOfOrder) {
+		t.Error("Expected ErrOutOfOrder, got", err)
+	} else if len(steps) != 0 {
+		t.Error("Unexpected steps", steps)
+	}
+	if v, err := m.Version(context.Background(), conn2); err != nil {
+		t.Error(err)
+	} else if v != 0 {
+		t.Error("Unexpected version", v)
+	}
+}
@@ -0,0 +1,29 @@
+package migrate
+
+import (
+	"reflect"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultSchema = "main"
+)
+
+var (
+	intType = reflect.TypeOf(int64(0))
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// inList returns true if a value is in a list of values
+func inList(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	// Modules
+	yaml "gopkg.in/yaml.v3"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+	. "github.com/mutablelogic/go-sqlite"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Policy authorizes statements according to the role of the caller, which
+// is set in the context of a transaction with WithRole. It implements the
+// SQAuth interface.
+type Policy struct {
+	Default string            `yaml:"default"` // Role when no role is set in the context
+	Users   map[string]string `yaml:"users"`   // User names mapped onto roles
+	Roles   map[string]*Role  `yaml:"roles"`   // Roles mapped onto permissions
+}
+
+// Role grants privileges on tables, and allows or denies pragmas, functions
+// and changes to the schema
+type Role struct {
+	DDL       bool    `yaml:"ddl"`       // Allow create, drop, alter, analyze, reindex, attach and detach
+	Pragmas   Rule    `yaml:"pragmas"`   // Pragmas which can be executed
+	Functions Rule    `yaml:"functions"` // Functions which can be called
+	Grants    []Grant `yaml:"grants"`    // Privileges on tables
+}
+
+// Rule allows or denies names, which can include wildcards. A name is
+// allowed when it matches an allow pattern and does not match a deny pattern.
+type Rule struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// Grant gives privileges on tables in a schema. An empty schema or table
+// matches all schemas or tables, and both can include wildcards.
+type Grant struct {
+	Schema     string   `yaml:"schema"`
+	Table      string   `yaml:"table"`
+	Privileges []string `yaml:"privileges"` // select, insert, update, delete or all
+	Columns    []string `yaml:"columns"`    // When set, only these columns can be read or updated
+	Mask       []string `yaml:"mask"`       // Columns which are read as NULL
+}
+
+// ctxKey is the type of the context key for the role
+type ctxKey struct{}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	PrivilegeSelect = "select"
+	PrivilegeInsert = "insert"
+	PrivilegeUpdate = "update"
+	PrivilegeDelete = "delete"
+	PrivilegeAll    = "all"
+)
+
+const (
+	// Tables with this prefix are used by sqlite and can always be accessed
+	internalPrefix = "sqlite_"
+
+	// Flags for actions which change the schema
+	ddlFlags = SQLITE_AUTH_CREATE | SQLITE_AUTH_DROP | SQLITE_AUTH_ALTER | SQLITE_AUTH_ANALYZE | SQLITE_AUTH_REINDEX | SQLITE_AUTH_ATTACH | SQLITE_AUTH_DETACH
+)
+
+var (
+	// ErrNotAuthorized is returned when a role does not allow an action
+	ErrNotAuthorized = errors.New("not authorized")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// New returns a policy read from YAML
+func New(r io.Reader) (*Policy, error) {
+	p := new(Policy)
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil {
+		return nil, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Read returns a policy read from a YAML file
+func Read(path string) (*Policy, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return New(r)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (p *Policy) String() string {
+	str := "<policy"
+	if p.Default != "" {
+		str += fmt.Sprintf(" default=%q", p.Default)
+	}
+	for name := range p.Roles {
+		str += fmt.Sprintf(" role=%q", name)
+	}
+	return str + ">"
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// WithRole returns a context with a role, which is used to authorize
+// statements executed in a transaction with the context
+func WithRole(ctx context.Context, role string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, ctxKey{}, role)
+}
+
+// RoleFromContext returns the role set in a context, or empty string
+func RoleFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	} else if role, ok := ctx.Value(ctxKey{}).(string); ok {
+		return role
+	} else {
+		return ""
+	}
+}
+
+// RoleForUser returns the role for a user, or the default role if the
+// user has no role
+func (p *Policy) RoleForUser(user string) string {
+	if role, exists := p.Users[user]; exists && user != "" {
+		return role
+	}
+	return p.Default
+}
+
+// CanSelect allows a SELECT statement. The columns read are authorized
+// separately.
+func (p *Policy) CanSelect(context.Context) error {
+	return nil
+}
+
+// CanTransaction allows transactions and savepoints
+func (p *Policy) CanTransaction(context.Context, SQAuthFlag) error {
+	return nil
+}
+
+// CanExec authorizes an action for the role in the context, or the default
+// role. Reading a column which is masked returns ErrAuthMask.
+func (p *Policy) CanExec(ctx context.Context, flag SQAuthFlag, schema string, args ...string) error {
+	name, role := p.role(ctx)
+	if role == nil {
+		return fmt.Errorf("%w: role %q: %v", ErrNotAuthorized, name, flag)
+	}
+
+	switch {
+	case flag.Is(SQLITE_AUTH_FUNCTION):
+		// The function name is passed as the schema
+		if !role.Functions.allow(schema) {
+			return fmt.Errorf("%w: role %q: function %q", ErrNotAuthorized, name, schema)
+		}
+	case flag.Is(SQLITE_AUTH_PRAGMA):
+		// The pragma name is passed as the schema
+		if !role.Pragmas.allow(schema) {
+			return fmt.Errorf("%w: role %q: pragma %q", ErrNotAuthorized, name, schema)
+		}
+	case flag.Is(ddlFlags):
+		if !role.DDL {
+			return fmt.Errorf("%w: role %q: %v", ErrNotAuthorized, name, flag)
+		}
+	case flag.Is(SQLITE_AUTH_READ):
+		return role.read(name, schema, arg(args, 0), arg(args, 1))
+	case flag.Is(SQLITE_AUTH_UPDATE):
+		return role.check(name, PrivilegeUpdate, schema, arg(args, 0), arg(args, 1))
+	case flag.Is(SQLITE_AUTH_INSERT):
+		return role.check(name, PrivilegeInsert, schema, arg(args, 0), "")
+	case flag.Is(SQLITE_AUTH_DELETE):
+		return role.check(name, PrivilegeDelete, schema, arg(args, 0), "")
+	default:
+		return fmt.Errorf("%w: role %q: %v", ErrNotAuthorized, name, flag)
+	}
+
+	// Return success
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// validate checks the roles and privileges
+func (p *Policy) validate() error {
+	if p.Default != "" && p.Roles[p.Default] == nil {
+		return ErrNotFound.Withf("Default role %q", p.Default)
+	}
+	for user, role := range p.Users {
+		if p.Roles[role] == nil {
+			return ErrNotFound.Withf("Role %q for user %q", role, user)
+		}
+	}
+	for name, role := range p.Roles {
+		if role == nil {
+			return ErrBadParameter.Withf("Role %q", name)
+		}
+		for _, grant := range role.Grants {
+			for _, privilege := range grant.Privileges {
+				switch strings.ToLower(privilege) {
+				case PrivilegeSelect, PrivilegeInsert, PrivilegeUpdate, PrivilegeDelete, PrivilegeAll:
+					continue
+				default:
+					return ErrBadParameter.Withf("Role %q: privilege %q", name, privilege)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// role returns the name of the role from the context, and the role
+func (p *Policy) role(ctx context.Context) (string, *Role) {
+	name := RoleFromContext(ctx)
+	if name == "" {
+		name = p.Default
+	}
+	return name, p.Roles[name]
+}
+
+// read returns nil if a column can be read, or ErrAuthMask if the column
+// is masked by every grant which allows the column to be read
+func (r *Role) read(name, schema, table, column string) error {
+	if strings.HasPrefix(table, internalPrefix) {
+		return nil
+	}
+	masked := false
+	for _, grant := range r.Grants {
+		if !grant.match(PrivilegeSelect, schema, table, column) {
+			continue
+		}
+		if column != "" && match(grant.Mask, column) {
+			masked = true
+		} else {
+			return nil
+		}
+	}
+	if masked {
+		return ErrAuthMask
+	}
+	return fmt.Errorf("%w: role %q: %s %q", ErrNotAuthorized, name, PrivilegeSelect, columnName(schema, table, column))
+}
+
+// check returns nil if a privilege is granted on a table and column
+func (r *Role) check(name, privilege, schema, table, column string) error {
+	if strings.HasPrefix(table, internalPrefix) {
+		return nil
+	}
+	for _, grant := range r.Grants {
+		if grant.match(privilege, schema, table, column) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: role %q: %s %q", ErrNotAuthorized, name, privilege, columnName(schema, table, column))
+}
+
+// match returns true if the grant gives a privilege on a table and column
+func (g *Grant) match(privilege, schema, table, column string) bool {
+	if g.Schema != "" && !match([]string{g.Schema}, schema) {
+		return false
+	}
+	if g.Table != "" && !match([]string{g.Table}, table) {
+		return false
+	}
+	if column != "" && len(g.Columns) > 0 && !match(g.Columns, column) {
+		return false
+	}
+	for _, v := range g.Privileges {
+		if v = strings.ToLower(v); v == privilege || v == PrivilegeAll {
+			return true
+		}
+	}
+	return false
+}
+
+// allow returns true if a name matches an allow pattern and does not match
+// a deny pattern
+func (r Rule) allow(name string) bool {
+	return match(r.Allow, name) && !match(r.Deny, name)
+}
+
+// match returns true if a name matches any of the patterns, ignoring case
+func match(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, err := path.Match(strings.ToLower(pattern), name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// arg returns an argument, or empty string
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+// columnName returns a name for a table or column in errors
+func columnName(schema, table, column string) string {
+	name := table
+	if schema != "" {
+		name = schema + "." + name
+	}
+	if column != "" {
+		name += "." + column
+	}
+	return name
+}
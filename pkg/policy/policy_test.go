@@ -0,0 +1,272 @@
+package policy_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+
+	// Namespace imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/policy"
+)
+
+const policy = `
+default: admin
+users:
+  alice: reader
+roles:
+  admin:
+    ddl: true
+    pragmas:
+      allow: ["*"]
+    functions:
+      allow: ["*"]
+    grants:
+      - privileges: [all]
+  reader:
+    functions:
+      allow: ["*"]
+      deny: [random]
+    grants:
+      - table: users
+        privileges: [select]
+        mask: [email]
+      - table: log_*
+        privileges: [select, insert]
+`
+
+const restricted = `
+default: reader
+roles:
+  admin:
+    ddl: true
+    grants:
+      - privileges: [all]
+  reader:
+    grants:
+      - table: test
+        privileges: [select]
+`
+
+func Test_Policy_001(t *testing.T) {
+	p, err := New(strings.NewReader(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role := p.RoleForUser("alice"); role != "reader" {
+		t.Error("Unexpected role", role)
+	}
+	if role := p.RoleForUser("bob"); role != "admin" {
+		t.Error("Unexpected role", role)
+	}
+
+	// Unknown roles and privileges are rejected
+	if _, err := New(strings.NewReader("default: other\n")); err == nil {
+		t.Error("Expected error for unknown role")
+	}
+	if _, err := New(strings.NewReader("roles:\n  a:\n    grants:\n      - privileges: [drop]\n")); err == nil {
+		t.Error("Expected error for unknown privilege")
+	}
+}
+
+func Test_Policy_002(t *testing.T) {
+	p, err := New(strings.NewReader(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := sqlite3.OpenPool(sqlite3.NewConfig().WithAuth(p), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn := pool.Get()
+	defer pool.Put(conn)
+
+	// Create tables with the default role
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		for _, st := range []SQStatement{
+			Q("CREATE TABLE users (name TEXT, email TEXT)"),
+			Q("CREATE TABLE log_a (message TEXT)"),
+			Q("INSERT INTO users VALUES ('alice', 'alice@example.com')"),
+		} {
+			if _, err := txn.Query(st); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reader can read users, but the email column is masked
+	reader := WithRole(context.Background(), p.RoleForUser("alice"))
+	if err := conn.Do(reader, 0, func(txn SQTransaction) error {
+		r, err := txn.Query(Q("SELECT name, email FROM users"))
+		if err != nil {
+			return err
+		}
+		if row := r.Next(); len(row) != 2 || row[0] != "alice" || row[1] != nil {
+			t.Error("Unexpected row", row)
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	// Reader can insert into log tables, but not users
+	if err := conn.Do(reader, 0, func(txn SQTransaction) error {
+		_, err := txn.Query(Q("INSERT INTO log_a VALUES ('message')"))
+		return err
+	}); err != nil {
+		t.Error(err)
+	}
+	for _, st := range []string{
+		"INSERT INTO users VALUES ('bob', 'bob@example.com')",
+		"DELETE FROM users",
+		"SELECT random()",
+		"DROP TABLE users",
+		"PRAGMA table_info(users)",
+	} {
+		if err := conn.Do(reader, 0, func(txn SQTransaction) error {
+			_, err := txn.Query(Q(st))
+			return err
+		}); err == nil {
+			t.Errorf("Expected error for %q", st)
+		}
+	}
+
+	// Unknown roles are not authorized
+	if err := p.CanExec(WithRole(context.Background(), "other"), SQLITE_AUTH_TABLE|SQLITE_AUTH_READ, "main", "users", "name"); !errors.Is(err, ErrNotAuthorized) {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func Test_Policy_003(t *testing.T) {
+	p, err := New(strings.NewReader(restricted))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Statements executed by the pool are not authorized, even though no
+	// role is allowed pragmas
+	cfg := sqlite3.NewConfig().WithSchema(sqlite3.DefaultSchema, filepath.Join(t.TempDir(), "test.sqlite")).WithWAL(true).WithAuth(p)
+	cfg = cfg.WithOnConnect(func(conn *sqlite3.Conn) error {
+		return conn.Exec(Q("PRAGMA cache_size=100"), nil)
+	})
+	pool, err := sqlite3.OpenPool(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+
+	// Admin can create the table and insert rows
+	admin := WithRole(context.Background(), "admin")
+	for _, flag := range []SQFlag{0, SQLITE_TXN_EXCLUSIVE | SQLITE_TXN_NO_FOREIGNKEY_CONSTRAINTS} {
+		if err := conn.Do(admin, flag, func(txn SQTransaction) error {
+			if _, err := txn.Query(Q("CREATE TABLE IF NOT EXISTS test (a TEXT)")); err != nil {
+				return err
+			}
+			_, err := txn.Query(Q("INSERT INTO test VALUES ('a')"))
+			return err
+		}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The default role can read in a read-only transaction, but not insert
+	if err := conn.Do(context.Background(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
+		r, err := txn.Query(Q("SELECT a FROM test"))
+		if err != nil {
+			return err
+		}
+		n := 0
+		for row := r.Next(); row != nil; row = r.Next() {
+			n++
+		}
+		if n != 2 {
+			t.Error("Unexpected number of rows", n)
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		_, err := txn.Query(Q("INSERT INTO test VALUES ('b')"))
+		return err
+	}); err == nil {
+		t.Error("Expected insert to be denied")
+	}
+}
+
+func Test_Policy_004(t *testing.T) {
+	p, err := New(strings.NewReader(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := sqlite3.OpenPool(sqlite3.NewConfig().WithAuth(p).WithCacheSize(10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+
+	admin := context.Background()
+	reader := WithRole(context.Background(), p.RoleForUser("alice"))
+	if err := conn.Do(admin, 0, func(txn SQTransaction) error {
+		_, err := txn.Query(Q("CREATE TABLE users (name TEXT, email TEXT)"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cached statements are authorized again for each role, so the same
+	// statement is masked for the reader but not for the admin
+	for _, ctx := range []context.Context{admin, reader, admin, reader} {
+		email := "alice@example.com"
+		if ctx == reader {
+			email = ""
+		}
+		insert := conn.Do(ctx, 0, func(txn SQTransaction) error {
+			_, err := txn.Query(Q("INSERT INTO users VALUES ('alice', 'alice@example.com')"))
+			return err
+		})
+		if ctx == reader && insert == nil {
+			t.Error("Expected insert to be denied for reader")
+		} else if ctx == admin && insert != nil {
+			t.Error(insert)
+		}
+		if err := conn.Do(ctx, 0, func(txn SQTransaction) error {
+			r, err := txn.Query(Q("SELECT name, email FROM users"))
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			row := r.Next()
+			if len(row) != 2 || row[0] != "alice" {
+				t.Error("Unexpected row", row)
+			} else if email == "" && row[1] != nil {
+				t.Error("Expected email to be masked, got", row[1])
+			} else if email != "" && row[1] != email {
+				t.Error("Expected email to be unmasked, got", row[1])
+			}
+			return nil
+		}); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package replicate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Dir is a destination which stores snapshots and segments in a directory,
+// with a subdirectory for each generation
+type Dir struct {
+	path string
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	dirSnapshotPrefix = "snapshot-"
+	dirSnapshotExt    = ".db"
+	dirSegmentExt     = ".wal"
+	dirTempExt        = ".tmp"
+	dirMode           = 0755
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// NewDir returns a destination which stores snapshots and segments in a
+// directory, which is created if it does not exist
+func NewDir(path string) (*Dir, error) {
+	if path == "" {
+		return nil, ErrBadParameter.With("NewDir: path")
+	} else if err := os.MkdirAll(path, dirMode); err != nil {
+		return nil, err
+	} else if path, err := filepath.Abs(path); err != nil {
+		return nil, err
+	} else {
+		return &Dir{path}, nil
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (d *Dir) String() string {
+	return fmt.Sprintf("<replicate.dir path=%q>", d.path)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// WriteSnapshot stores the snapshot for a new generation
+func (d *Dir) WriteSnapshot(snapshot Snapshot, r io.Reader) error {
+	if !isGeneration(snapshot.Generation) {
+		return ErrBadParameter.Withf("WriteSnapshot: %q", snapshot.Generation)
+	}
+	name := dirSnapshotPrefix + timestamp(snapshot.Time) + dirSnapshotExt
+	return d.write(filepath.Join(d.path, snapshot.Generation), name, r)
+}
+
+// Snapshots returns the snapshots for all generations, oldest first
+func (d *Dir) Snapshots() ([]Snapshot, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Snapshot, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !isGeneration(entry.Name()) {
+			continue
+		}
+		if name, err := d.snapshot(entry.Name()); err != nil {
+			return nil, err
+		} else if name != "" {
+			t, _ := parseTimestamp(strings.TrimSuffix(strings.TrimPrefix(name, dirSnapshotPrefix), dirSnapshotExt))
+			result = append(result, Snapshot{Generation: entry.Name(), Time: t})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+// ReadSnapshot returns the snapshot for a generation
+func (d *Dir) ReadSnapshot(generation string) (io.ReadCloser, error) {
+	if !isGeneration(generation) {
+		return nil, ErrBadParameter.Withf("ReadSnapshot: %q", generation)
+	} else if name, err := d.snapshot(generation); err != nil {
+		return nil, err
+	} else if name == "" {
+		return nil, ErrNotFound.Withf("Snapshot for generation %q", generation)
+	} else {
+		return os.Open(filepath.Join(d.path, generation, name))
+	}
+}
+
+// WriteSegment stores a segment of the write-ahead log
+func (d *Dir) WriteSegment(segment Segment, r io.Reader) error {
+	if !isGeneration(segment.Generation) {
+		return ErrBadParameter.Withf("WriteSegment: %q", segment.Generation)
+	}
+	return d.write(filepath.Join(d.path, segment.Generation), segmentName(segment), r)
+}
+
+// Segments returns the segments for a generation, in the order they were
+// written
+func (d *Dir) Segments(generation string) ([]Segment, error) {
+	if !isGeneration(generation) {
+		return nil, ErrBadParameter.Withf("Segments: %q", generation)
+	}
+	entries, err := os.ReadDir(filepath.Join(d.path, generation))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Segment, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != dirSegmentExt {
+			continue
+		}
+		segment := Segment{Generation: generation}
+		var index uint32
+		var offset int64
+		var ts string
+		if _, err := fmt.Sscanf(strings.TrimSuffix(entry.Name(), dirSegmentExt), "%08x-%016x-%s", &index, &offset, &ts); err != nil {
+			continue
+		} else if t, err := parseTimestamp(ts); err != nil {
+			continue
+		} else {
+			segment.Index, segment.Offset, segment.Time = index, offset, t
+		}
+		result = append(result, segment)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].before(result[j])
+	})
+	return result, nil
+}
+
+// ReadSegment returns the frames of a segment
+func (d *Dir) ReadSegment(segment Segment) (io.ReadCloser, error) {
+	if !isGeneration(segment.Generation) {
+		return nil, ErrBadParameter.Withf("ReadSegment: %q", segment.Generation)
+	}
+	return os.Open(filepath.Join(d.path, segment.Generation, segmentName(segment)))
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// write writes a file atomically into a directory, which is created if it
+// does not exist
+func (d *Dir) write(dir, name string, r io.Reader) error {
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return err
+	}
+	w, err := os.CreateTemp(dir, name+".*"+dirTempExt)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(w.Name())
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Sync(); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.Rename(w.Name(), filepath.Join(dir, name))
+}
+
+// snapshot returns the name of the snapshot file for a generation, or empty
+// string if there is no snapshot
+func (d *Dir) snapshot(generation string) (string, error) {
+	entries, err := os.ReadDir(filepath.Join(d.path, generation))
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, dirSnapshotPrefix) && filepath.Ext(name) == dirSnapshotExt {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// segmentName returns the file name for a segment, which sorts in the order
+// segments are written
+func segmentName(segment Segment) string {
+	return fmt.Sprintf("%08x-%016x-%s%s", segment.Index, segment.Offset, timestamp(segment.Time), dirSegmentExt)
+}
+
+// timestamp returns a time as a string which sorts in time order
+func timestamp(t time.Time) string {
+	return fmt.Sprintf("%016x", t.UnixNano())
+}
+
+// parseTimestamp returns the time for a timestamp
+func parseTimestamp(ts string) (time.Time, error) {
+	var ns int64
+	if _, err := fmt.Sscanf(ts, "%016x", &ns); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ns), nil
+}
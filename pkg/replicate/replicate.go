@@ -0,0 +1,366 @@
+package replicate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	// Packages
+	sqlite3 "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+	sys "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Destination stores snapshots of a database and the segments of the
+// write-ahead log committed after each snapshot
+type Destination interface {
+	// Store the snapshot which starts a new generation
+	WriteSnapshot(Snapshot, io.Reader) error
+
+	// Return the snapshots for all generations, oldest first
+	Snapshots() ([]Snapshot, error)
+
+	// Return the snapshot for a generation
+	ReadSnapshot(generation string) (io.ReadCloser, error)
+
+	// Store a segment of the write-ahead log
+	WriteSegment(Segment, io.Reader) error
+
+	// Return the segments for a generation, in the order they were written
+	Segments(generation string) ([]Segment, error)
+
+	// Return the frames of a segment
+	ReadSegment(Segment) (io.ReadCloser, error)
+}
+
+// Snapshot is a copy of the database which starts a generation. Segments
+// in the generation are applied to the snapshot to restore the database.
+type Snapshot struct {
+	Generation string
+	Time       time.Time
+}
+
+// Segment is a set of frames from the write-ahead log which ends with a
+// commit. The index increments each time the log is restarted, and the offset
+// is the position of the first frame in the log.
+type Segment struct {
+	Generation string
+	Index      uint32
+	Offset     int64
+	Time       time.Time
+}
+
+// Replicator copies committed changes for a schema to a destination
+type Replicator struct {
+	sync.Mutex
+	pool       *sqlite3.Pool
+	schema     string
+	dest       Destination
+	size       int64     // Size of the log which triggers a checkpoint
+	generation string    // Current generation, or empty
+	index      uint32    // Current index of the log
+	salt       [2]uint32 // Salt values for the log
+	offset     int64     // Offset of the next frame to replicate
+	checksum   [2]uint32 // Checksum of the last frame replicated
+	restart    bool      // True when the log has been checkpointed by the replicator
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// The default size of the write-ahead log which triggers a checkpoint
+	DefaultCheckpointSize = 4 * 1024 * 1024
+)
+
+var (
+	reGeneration = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// New returns a replicator for a schema in a pool, which must be in WAL
+// mode. Automatic checkpoints are disabled for the writer connection, so
+// that the replicator controls when the log is restarted.
+func New(pool *sqlite3.Pool, schema string, dest Destination) (*Replicator, error) {
+	r := new(Replicator)
+	if pool == nil || dest == nil {
+		return nil, ErrBadParameter.With("New")
+	}
+	if schema == "" {
+		schema = sqlite3.DefaultSchema
+	}
+	r.pool, r.schema, r.dest = pool, schema, dest
+	r.size = DefaultCheckpointSize
+
+	// Disable automatic checkpoints and check the schema is a file
+	if err := pool.Writer(func(conn *sqlite3.Conn) error {
+		if conn.Filename(schema) == "" {
+			return ErrBadParameter.Withf("New: schema %q is not a file", schema)
+		}
+		return conn.Exec(Q("PRAGMA ", N(schema), ".wal_autocheckpoint=0"), nil)
+	}); err != nil {
+		return nil, err
+	}
+
+	// Return success
+	return r, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (r *Replicator) String() string {
+	str := "<replicator"
+	str += fmt.Sprintf(" schema=%q", r.schema)
+	if r.generation != "" {
+		str += fmt.Sprintf(" generation=%q", r.generation)
+		str += fmt.Sprint(" index=", r.index)
+		str += fmt.Sprint(" offset=", r.offset)
+	}
+	str += fmt.Sprint(" dest=", r.dest)
+	return str + ">"
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// SetCheckpointSize sets the size of the write-ahead log which triggers
+// a checkpoint after a sync, or zero to only checkpoint when Checkpoint
+// is called
+func (r *Replicator) SetCheckpointSize(size int64) {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	r.size = size
+}
+
+// Generation returns the current generation, or empty string if no snapshot
+// has been taken
+func (r *Replicator) Generation() string {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	return r.generation
+}
+
+// Run syncs changes to the destination at an interval until the context is
+// cancelled, and then syncs any remaining changes
+func (r *Replicator) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return ErrBadParameter.With("Run: interval")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return r.Sync()
+		case <-ticker.C:
+			if err := r.Sync(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Sync copies committed changes to the destination. A snapshot is taken
+// to start a new generation on the first sync, or when changes may have
+// been lost since the last sync because the log was restarted elsewhere.
+func (r *Replicator) Sync() error {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	return r.pool.Writer(func(conn *sqlite3.Conn) error {
+		if err := r.sync(conn); err != nil {
+			return err
+		}
+		if r.size > 0 && r.offset >= r.size {
+			return r.checkpoint(conn)
+		}
+		return nil
+	})
+}
+
+// Checkpoint copies committed changes to the destination, then transfers
+// the log into the database and restarts the log
+func (r *Replicator) Checkpoint() error {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	return r.pool.Writer(func(conn *sqlite3.Conn) error {
+		if err := r.sync(conn); err != nil {
+			return err
+		}
+		return r.checkpoint(conn)
+	})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// sync copies frames committed since the last sync, and is called with the
+// writer connection so no frames are written concurrently
+func (r *Replicator) sync(conn *sqlite3.Conn) error {
+	if r.generation == "" {
+		return r.snapshot(conn)
+	}
+
+	// Open the log
+	f, err := os.Open(conn.Filename(r.schema) + "-wal")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Read the header, and check for a restart of the log
+	hdr, err := readWALHeader(f)
+	if errors.Is(err, io.EOF) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if hdr.salt != r.salt {
+		if !r.restart {
+			// Frames may have been lost, so start a new generation
+			return r.snapshot(conn)
+		}
+		r.index, r.salt, r.offset, r.checksum, r.restart = r.index+1, hdr.salt, walHeaderSize, hdr.checksum, false
+	}
+
+	// Read frames up to the last commit
+	frames, offset, checksum, err := hdr.readFrames(f, r.offset, r.checksum)
+	if err != nil {
+		return err
+	} else if len(frames) == 0 {
+		return nil
+	}
+
+	// Write the segment
+	segment := Segment{Generation: r.generation, Index: r.index, Offset: r.offset, Time: time.Now()}
+	if err := r.dest.WriteSegment(segment, bytes.NewReader(frames)); err != nil {
+		return err
+	}
+	r.offset, r.checksum = offset, checksum
+
+	// Return success
+	return nil
+}
+
+// checkpoint transfers the log into the database and truncates the log. The
+// log is not restarted when the checkpoint is blocked by a reader, in which
+// case a restart is left to be detected on the next sync
+func (r *Replicator) checkpoint(conn *sqlite3.Conn) error {
+	var busy string
+	if err := conn.Exec(Q("PRAGMA ", N(r.schema), ".wal_checkpoint(TRUNCATE)"), func(row, _ []string) bool {
+		busy = row[0]
+		return false
+	}); err != nil {
+		return err
+	}
+	if busy == "0" {
+		r.restart = true
+	}
+	return nil
+}
+
+// snapshot starts a new generation with a copy of the database, and
+// positions the replicator at the end of the log
+func (r *Replicator) snapshot(conn *sqlite3.Conn) error {
+	if err := r.checkpoint(conn); err != nil {
+		return err
+	}
+
+	// Backup into a temporary file
+	now := time.Now()
+	snapshot := Snapshot{Generation: timestamp(now), Time: now}
+	tmp, err := os.CreateTemp("", "snapshot-*"+dirSnapshotExt)
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := backup(conn, r.schema, tmp.Name()); err != nil {
+		return err
+	}
+
+	// Write the snapshot
+	if f, err := os.Open(tmp.Name()); err != nil {
+		return err
+	} else if err := r.dest.WriteSnapshot(snapshot, f); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Set the generation, and position at the end of the log in case the
+	// checkpoint could not restart the log
+	r.generation, r.index, r.salt, r.offset, r.checksum = snapshot.Generation, 0, [2]uint32{}, walHeaderSize, [2]uint32{}
+	f, err := os.Open(conn.Filename(r.schema) + "-wal")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	if hdr, err := readWALHeader(f); errors.Is(err, io.EOF) {
+		return nil
+	} else if err != nil {
+		return err
+	} else if _, offset, checksum, err := hdr.readFrames(f, walHeaderSize, hdr.checksum); err != nil {
+		return err
+	} else {
+		r.salt, r.offset, r.checksum = hdr.salt, offset, checksum
+	}
+
+	// Return success
+	return nil
+}
+
+// backup copies a schema into a new database file
+func backup(conn *sqlite3.Conn, schema, path string) error {
+	dest, err := sqlite3.OpenPath(path, SQFlag(sys.SQLITE_OPEN_CREATE|sys.SQLITE_OPEN_READWRITE))
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	b, err := conn.OpenBackup(dest.ConnEx.Conn, "", schema)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := b.Step(-1); err == sys.SQLITE_DONE {
+			break
+		} else if err != nil {
+			b.Finish()
+			return err
+		}
+	}
+	return b.Finish()
+}
+
+// isGeneration returns true if a generation name is valid
+func isGeneration(generation string) bool {
+	return reGeneration.MatchString(generation)
+}
+
+// before returns true if a segment was written before another segment
+func (s Segment) before(other Segment) bool {
+	if s.Index != other.Index {
+		return s.Index < other.Index
+	}
+	return s.Offset < other.Offset
+}
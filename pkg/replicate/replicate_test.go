@@ -0,0 +1,189 @@
+package replicate_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// Packages
+	sqlite3 "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/replicate"
+)
+
+func Test_Replicate_001(t *testing.T) {
+	tmp := t.TempDir()
+	pool, err := sqlite3.OpenPool(sqlite3.NewConfig().WithSchema("main", filepath.Join(tmp, "main.sqlite")).WithWAL(true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	dest, err := NewDir(filepath.Join(tmp, "replica"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(pool, "", dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a table and take the snapshot
+	insert(t, pool, 0)
+	if err := r.Sync(); err != nil {
+		t.Fatal(err)
+	} else if r.Generation() == "" {
+		t.Fatal("Expected a generation")
+	} else {
+		t.Log(r)
+	}
+
+	// Replicate rows as segments, restarting the log between them
+	insert(t, pool, 10)
+	if err := r.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	insert(t, pool, 10)
+	if err := r.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	insert(t, pool, 10)
+	if err := r.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if segments, err := dest.Segments(r.Generation()); err != nil {
+		t.Fatal(err)
+	} else if len(segments) != 3 {
+		t.Error("Unexpected segments", segments)
+	}
+
+	// Restore all changes, and changes up to a point in time
+	if err := Restore(dest, filepath.Join(tmp, "latest.sqlite"), time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if n := count(t, filepath.Join(tmp, "latest.sqlite")); n != 30 {
+		t.Error("Unexpected count", n)
+	}
+	if err := Restore(dest, filepath.Join(tmp, "ts.sqlite"), ts); err != nil {
+		t.Fatal(err)
+	} else if n := count(t, filepath.Join(tmp, "ts.sqlite")); n != 20 {
+		t.Error("Unexpected count", n)
+	}
+	if err := Restore(dest, filepath.Join(tmp, "ts.sqlite"), ts); err == nil {
+		t.Error("Expected error when restoring over an existing file")
+	}
+}
+
+func Test_Replicate_002(t *testing.T) {
+	tmp := t.TempDir()
+	pool, err := sqlite3.OpenPool(sqlite3.NewConfig().WithSchema("main", filepath.Join(tmp, "main.sqlite")).WithWAL(true).WithBusyTimeout(50*time.Millisecond), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	dest, err := NewDir(filepath.Join(tmp, "replica"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(pool, "", dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(t, pool, 10)
+	if err := r.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	generation := r.Generation()
+
+	// Checkpoint while a reader holds the log open, so the log is not restarted
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	reading, done := make(chan struct{}), make(chan error)
+	go func() {
+		done <- conn.Do(context.Background(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
+			if r, err := txn.Query(Q("SELECT COUNT(*) FROM test")); err != nil {
+				return err
+			} else {
+				r.Close()
+			}
+			reading <- struct{}{}
+			<-reading
+			return nil
+		})
+	}()
+	<-reading
+	insert(t, pool, 10)
+	if err := r.Checkpoint(); err != nil {
+		t.Error(err)
+	}
+	reading <- struct{}{}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	pool.Put(conn)
+
+	// Rows written before the log is restarted elsewhere start a new generation
+	insert(t, pool, 10)
+	if err := pool.Writer(func(conn *sqlite3.Conn) error {
+		return conn.Exec(Q("PRAGMA wal_checkpoint(TRUNCATE)"), nil)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	insert(t, pool, 10)
+	if err := r.Sync(); err != nil {
+		t.Fatal(err)
+	} else if r.Generation() == generation {
+		t.Error("Expected a new generation")
+	}
+	if err := Restore(dest, filepath.Join(tmp, "latest.sqlite"), time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if n := count(t, filepath.Join(tmp, "latest.sqlite")); n != 40 {
+		t.Error("Unexpected count", n)
+	}
+}
+
+func insert(t *testing.T, pool *sqlite3.Pool, n int) {
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		if _, err := txn.Query(N("test").CreateTable(C("a")).IfNotExists()); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if _, err := txn.Query(N("test").Insert("a"), i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func count(t *testing.T, path string) int {
+	conn, err := sqlite3.OpenPath(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	rs, err := conn.Query(Q("SELECT COUNT(*) FROM test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := rs.Next()
+	if row == nil {
+		t.Fatal("Unexpected nil row")
+	}
+	return int(row[0].(int64))
+}
@@ -0,0 +1,157 @@
+package replicate
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Offset of the page size in the database header
+	dbPageSizeOffset = 16
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Restore writes a database to path from the latest snapshot taken at or
+// before time t, applying the segments written at or before t. When t is
+// zero, the latest snapshot and all its segments are restored. The path
+// must not already exist.
+func Restore(src Destination, path string, t time.Time) error {
+	if src == nil || path == "" {
+		return ErrBadParameter.With("Restore")
+	} else if _, err := os.Stat(path); err == nil {
+		return ErrDuplicateEntry.Withf("Restore: %q", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// Determine the snapshot to restore
+	snapshots, err := src.Snapshots()
+	if err != nil {
+		return err
+	}
+	var snapshot *Snapshot
+	for i := range snapshots {
+		if t.IsZero() || !snapshots[i].Time.After(t) {
+			snapshot = &snapshots[i]
+		}
+	}
+	if snapshot == nil {
+		return ErrNotFound.Withf("Restore: no snapshot at %v", t)
+	}
+
+	// Restore into a temporary file, which is renamed on success
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*"+dirTempExt)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := restore(src, f, *snapshot, t); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// restore copies a snapshot into a file and applies the segments
+func restore(src Destination, f *os.File, snapshot Snapshot, t time.Time) error {
+	if r, err := src.ReadSnapshot(snapshot.Generation); err != nil {
+		return err
+	} else if _, err := io.Copy(f, r); err != nil {
+		r.Close()
+		return err
+	} else if err := r.Close(); err != nil {
+		return err
+	}
+
+	// Read the page size
+	var data [2]byte
+	if _, err := f.ReadAt(data[:], dbPageSizeOffset); err != nil {
+		return err
+	}
+	pageSize := int64(binary.BigEndian.Uint16(data[:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+
+	// Apply segments in order, checking none are missing
+	segments, err := src.Segments(snapshot.Generation)
+	if err != nil {
+		return err
+	}
+	var next *Segment
+	for _, segment := range segments {
+		if !t.IsZero() && segment.Time.After(t) {
+			break
+		}
+		if next == nil && segment.Index > 0 && (segment.Index != 1 || segment.Offset != walHeaderSize) {
+			return ErrOutOfOrder.With("Restore: missing segment at index 1")
+		} else if next != nil && segment.Index == next.Index && segment.Offset != next.Offset {
+			return ErrOutOfOrder.Withf("Restore: missing segment at index %v offset %v", next.Index, next.Offset)
+		} else if next != nil && segment.Index != next.Index && (segment.Index != next.Index+1 || segment.Offset != walHeaderSize) {
+			return ErrOutOfOrder.Withf("Restore: missing segment at index %v", next.Index+1)
+		}
+		n, err := apply(src, f, segment, pageSize)
+		if err != nil {
+			return err
+		}
+		next = &Segment{Index: segment.Index, Offset: segment.Offset + n}
+	}
+
+	// Return success
+	return nil
+}
+
+// apply writes the pages in a segment to a file, truncating the file on
+// each commit, and returns the size of the segment
+func apply(src Destination, f *os.File, segment Segment, pageSize int64) (int64, error) {
+	r, err := src.ReadSegment(segment)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	var n int64
+	data := make([]byte, walFrameSize+pageSize)
+	for {
+		if _, err := io.ReadFull(r, data); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		frame := decodeFrame(data)
+		if _, err := f.WriteAt(data[walFrameSize:], int64(frame.pgno-1)*pageSize); err != nil {
+			return 0, err
+		}
+		if frame.commit != 0 {
+			if err := f.Truncate(int64(frame.commit) * pageSize); err != nil {
+				return 0, err
+			}
+		}
+		n += int64(len(data))
+	}
+
+	// Return success
+	return n, nil
+}
@@ -0,0 +1,148 @@
+package replicate
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// walHeader is the header at the start of a write-ahead log
+type walHeader struct {
+	bigEndian bool      // Byte order of checksums
+	pageSize  uint32    // Database page size
+	salt      [2]uint32 // Salt values, which change when the log is restarted
+	checksum  [2]uint32 // Checksum of the header
+}
+
+// walFrame is the header for a page in the write-ahead log
+type walFrame struct {
+	pgno     uint32    // Page number
+	commit   uint32    // Size of the database in pages for commit frames, or zero
+	salt     [2]uint32 // Salt values copied from the header
+	checksum [2]uint32 // Cumulative checksum up to and including this frame
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	walHeaderSize = 32
+	walFrameSize  = 24
+	walMagic      = 0x377f0682
+	walVersion    = 3007000
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// readWALHeader reads and validates the header of a write-ahead log. It
+// returns io.EOF if the log is empty.
+func readWALHeader(r io.ReaderAt) (*walHeader, error) {
+	var data [walHeaderSize]byte
+	if n, err := r.ReadAt(data[:], 0); n == 0 && (err == nil || errors.Is(err, io.EOF)) {
+		return nil, io.EOF
+	} else if n < walHeaderSize {
+		return nil, ErrUnexpectedResponse.With("Write-ahead log header is truncated")
+	}
+
+	// Check magic number and version
+	h := new(walHeader)
+	magic := binary.BigEndian.Uint32(data[0:])
+	if magic&^1 != walMagic {
+		return nil, ErrUnexpectedResponse.With("Invalid write-ahead log magic number")
+	} else if version := binary.BigEndian.Uint32(data[4:]); version != walVersion {
+		return nil, ErrNotImplemented.Withf("Write-ahead log version %v", version)
+	}
+	h.bigEndian = magic&1 == 1
+	h.pageSize = binary.BigEndian.Uint32(data[8:])
+	h.salt = [2]uint32{binary.BigEndian.Uint32(data[16:]), binary.BigEndian.Uint32(data[20:])}
+	h.checksum = [2]uint32{binary.BigEndian.Uint32(data[24:]), binary.BigEndian.Uint32(data[28:])}
+
+	// Validate the checksum
+	if h.walChecksum(data[:24], [2]uint32{}) != h.checksum {
+		return nil, ErrUnexpectedResponse.With("Invalid write-ahead log header checksum")
+	}
+
+	// Return success
+	return h, nil
+}
+
+// readFrames reads valid frames from an offset in the write-ahead log, seeded
+// with the checksum of the previous frame. It returns the frames up to and
+// including the last commit frame, and the offset and checksum after that
+// frame. Frames after the last commit are not returned.
+func (h *walHeader) readFrames(r io.ReaderAt, offset int64, checksum [2]uint32) ([]byte, int64, [2]uint32, error) {
+	var result []byte
+	var end int
+	sum := checksum
+	size := walFrameSize + int(h.pageSize)
+	for {
+		data := make([]byte, size)
+		if n, err := r.ReadAt(data, offset+int64(len(result))); n < size {
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, 0, checksum, err
+			}
+			break
+		}
+		frame, next := h.frame(data, sum)
+		if frame == nil {
+			break
+		}
+		result, sum = append(result, data...), next
+		if frame.commit != 0 {
+			end, checksum = len(result), sum
+		}
+	}
+
+	// Return frames up to the last commit frame
+	if end == 0 {
+		return nil, offset, checksum, nil
+	}
+	return result[:end], offset + int64(end), checksum, nil
+}
+
+// frame decodes and validates a frame, seeded with the checksum of the
+// previous frame. It returns nil if the frame is not valid, which is the
+// case for frames left over from before the log was restarted.
+func (h *walHeader) frame(data []byte, checksum [2]uint32) (*walFrame, [2]uint32) {
+	frame := decodeFrame(data)
+	if frame.salt != h.salt {
+		return nil, checksum
+	}
+	checksum = h.walChecksum(data[:8], checksum)
+	checksum = h.walChecksum(data[walFrameSize:], checksum)
+	if checksum != frame.checksum {
+		return nil, checksum
+	}
+	return frame, checksum
+}
+
+// walChecksum computes the checksum over data, which is a multiple of
+// eight bytes, seeded with a previous checksum
+func (h *walHeader) walChecksum(data []byte, s [2]uint32) [2]uint32 {
+	var order binary.ByteOrder = binary.LittleEndian
+	if h.bigEndian {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(data); i += 8 {
+		s[0] += order.Uint32(data[i:]) + s[1]
+		s[1] += order.Uint32(data[i+4:]) + s[0]
+	}
+	return s
+}
+
+// decodeFrame returns the header for a frame
+func decodeFrame(data []byte) *walFrame {
+	return &walFrame{
+		pgno:     binary.BigEndian.Uint32(data[0:]),
+		commit:   binary.BigEndian.Uint32(data[4:]),
+		salt:     [2]uint32{binary.BigEndian.Uint32(data[8:]), binary.BigEndian.Uint32(data[12:])},
+		checksum: [2]uint32{binary.BigEndian.Uint32(data[16:]), binary.BigEndian.Uint32(data[20:])},
+	}
+}
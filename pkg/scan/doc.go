@@ -0,0 +1,6 @@
+/*
+Package scan reads rows of query results into structs, maps and slices of
+structs. Columns are matched to struct fields by the sqlite tag name or
+else the field name.
+*/
+package scan
@@ -0,0 +1,277 @@
+package scan
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	// Modules
+	marshaler "github.com/djthorpe/go-marshaler"
+
+	// Import namespaces
+	. "github.com/djthorpe/go-errors"
+	. "github.com/mutablelogic/go-sqlite"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// scanner maps result columns onto fields of a struct
+type scanner struct {
+	fields []int          // Field index for each column, or -1
+	cast   []reflect.Type // Type to cast each column to, or nil
+}
+
+// structFields are the fields of a struct type, keyed by column name
+type structFields map[string]*marshaler.Field
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// Cache of fields for struct types
+	fieldCache sync.Map
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+var (
+	// Layouts for parsing times which are stored as text, including the
+	// format of CURRENT_TIMESTAMP
+	timeLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02",
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// ScanStruct reads the next row of results into v, which should be a pointer
+// to a struct. Columns are matched to fields by the sqlite tag name or else
+// the field name, and columns which do not match a field are ignored. Returns
+// io.EOF when all rows have been read.
+func ScanStruct(rs SQResults, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return ErrBadParameter.Withf("ScanStruct: %T", v)
+	}
+	s, err := newScanner(rv.Elem().Type(), rs.Columns())
+	if err != nil {
+		return err
+	}
+	return s.scan(rs, rv.Elem())
+}
+
+// ScanMap reads the next row of results into a map of column names to
+// values. Returns io.EOF when all rows have been read.
+func ScanMap(rs SQResults) (map[string]interface{}, error) {
+	cols := rs.Columns()
+	row := rs.Next()
+	if row == nil {
+		return nil, io.EOF
+	}
+	result := make(map[string]interface{}, len(row))
+	for i, v := range row {
+		if i >= len(cols) {
+			break
+		}
+		// Copy blobs, which are not transient
+		if b, ok := v.([]byte); ok {
+			v = append([]byte{}, b...)
+		}
+		result[cols[i].Name()] = v
+	}
+	return result, nil
+}
+
+// ScanAll reads all remaining rows of results into v, which should be a
+// pointer to a slice of structs or a slice of pointers to structs. Rows are
+// appended to the slice.
+func ScanAll(rs SQResults, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return ErrBadParameter.Withf("ScanAll: %T", v)
+	}
+	slice := rv.Elem()
+	elem := slice.Type().Elem()
+	ptr := elem.Kind() == reflect.Ptr
+	if ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return ErrBadParameter.Withf("ScanAll: %T", v)
+	}
+
+	// Map the columns to fields once
+	s, err := newScanner(elem, rs.Columns())
+	if err != nil {
+		return err
+	}
+
+	// Read rows until io.EOF
+	for {
+		row := reflect.New(elem)
+		if err := s.scan(rs, row.Elem()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if ptr {
+			slice.Set(reflect.Append(slice, row))
+		} else {
+			slice.Set(reflect.Append(slice, row.Elem()))
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// fieldsFor returns the cached fields for a struct type
+func fieldsFor(t reflect.Type) (structFields, error) {
+	if fields, exists := fieldCache.Load(t); exists {
+		return fields.(structFields), nil
+	}
+	fields := make(structFields)
+	for _, field := range marshaler.NewEncoder(TagName).Reflect(reflect.New(t).Interface()) {
+		if field == nil {
+			// Ignored fields
+			continue
+		}
+		if _, exists := fields[field.Name]; exists {
+			return nil, ErrDuplicateEntry.Withf("%v: %q", t, field.Name)
+		}
+		fields[field.Name] = field
+	}
+	fieldCache.Store(t, fields)
+	return fields, nil
+}
+
+// field returns the field for a column name, falling back to a
+// case-insensitive match, or nil if there is no matching field
+func (fields structFields) field(name string) *marshaler.Field {
+	if field, exists := fields[name]; exists {
+		return field
+	}
+	for other, field := range fields {
+		if strings.EqualFold(other, name) {
+			return field
+		}
+	}
+	return nil
+}
+
+// newScanner returns a mapping from columns to the fields of a struct type
+func newScanner(t reflect.Type, cols []SQColumn) (*scanner, error) {
+	fields, err := fieldsFor(t)
+	if err != nil {
+		return nil, err
+	}
+	s := &scanner{make([]int, len(cols)), make([]reflect.Type, len(cols))}
+	for i, col := range cols {
+		s.fields[i] = -1
+		if field := fields.field(col.Name()); field != nil {
+			s.fields[i] = field.Index
+			// Pointer fields are not cast, so that NULL values can be
+			// distinguished from zero values
+			if field.Type.Kind() != reflect.Ptr {
+				s.cast[i] = field.Type
+			}
+		}
+	}
+	return s, nil
+}
+
+// scan reads the next row into a struct value, or returns io.EOF
+func (s *scanner) scan(rs SQResults, v reflect.Value) error {
+	row := rs.Next(s.cast...)
+	if row == nil {
+		return io.EOF
+	}
+	for i, value := range row {
+		if i >= len(s.fields) || s.fields[i] < 0 {
+			continue
+		}
+		field := v.Field(s.fields[i])
+		if err := setValue(field, value); err != nil {
+			return ErrBadParameter.Withf("%s: %v", v.Type().Field(s.fields[i]).Name, err)
+		}
+	}
+	return nil
+}
+
+// setValue sets a field to a value, converting the value if necessary
+func setValue(field reflect.Value, v interface{}) error {
+	t := field.Type()
+	if v == nil {
+		field.Set(reflect.Zero(t))
+		return nil
+	}
+
+	// Allocate pointer values
+	if t.Kind() == reflect.Ptr {
+		ptr := reflect.New(t.Elem())
+		if err := setValue(ptr.Elem(), v); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	// Copy blobs, which are not transient
+	if b, ok := v.([]byte); ok {
+		v = append([]byte{}, b...)
+	}
+
+	// Parse times which have not been cast
+	if t == timeType {
+		switch v := v.(type) {
+		case string:
+			if ts, err := parseTime(v); err != nil {
+				return err
+			} else {
+				field.Set(reflect.ValueOf(ts))
+				return nil
+			}
+		case int64:
+			field.Set(reflect.ValueOf(time.Unix(v, 0)))
+			return nil
+		}
+	}
+
+	// Assign or convert, but don't convert numbers into strings
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Type().AssignableTo(t):
+		field.Set(rv)
+	case t.Kind() == reflect.String && rv.Kind() != reflect.String:
+		return ErrBadParameter.Withf("Cannot scan %T into %v", v, t)
+	case rv.Type().ConvertibleTo(t):
+		field.Set(rv.Convert(t))
+	default:
+		return ErrBadParameter.Withf("Cannot scan %T into %v", v, t)
+	}
+	return nil
+}
+
+// parseTime parses a time stored as text. Times without a timezone, such as
+// those set by CURRENT_TIMESTAMP, are in UTC.
+func parseTime(v string) (time.Time, error) {
+	var result error
+	for _, layout := range timeLayouts {
+		if ts, err := time.Parse(layout, v); err == nil {
+			return ts, nil
+		} else if result == nil {
+			result = err
+		}
+	}
+	return time.Time{}, result
+}
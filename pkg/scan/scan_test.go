@@ -0,0 +1,128 @@
+package scan_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+
+	// Namespace imports
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+)
+
+type TestScanStructA struct {
+	Id       int64 `sqlite:"rowid"`
+	Name     string
+	Size     int32      `sqlite:"size"`
+	Modified time.Time  `sqlite:"modtime"`
+	Comment  *string    `sqlite:"comment"`
+	Deleted  *time.Time `sqlite:"deleted"`
+	Ignored  string     `sqlite:"-"`
+}
+
+func Test_Scan_001(t *testing.T) {
+	conn, err := sqlite3.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Create a table and insert rows
+	if err := conn.Exec(Q("CREATE TABLE test (name TEXT, size INTEGER, modtime TIMESTAMP, comment TEXT, deleted TIMESTAMP, other TEXT)"), nil); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second).UTC()
+	if err := conn.Exec(Q("INSERT INTO test VALUES ('a', 100, ", V(now.Format(time.RFC3339)), ", NULL, NULL, 'x')"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Exec(Q("INSERT INTO test VALUES ('b', 200, ", V(now.Format(time.RFC3339)), ", 'comment', ", V(now.Format(time.RFC3339)), ", 'y')"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scan into structs
+	r, err := conn.Query(Q("SELECT rowid, * FROM test ORDER BY rowid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v TestScanStructA
+	if err := r.ScanStruct(&v); err != nil {
+		t.Fatal(err)
+	} else if v.Id != 1 || v.Name != "a" || v.Size != 100 || !v.Modified.Equal(now) || v.Comment != nil || v.Deleted != nil {
+		t.Error("Unexpected row", v)
+	}
+	if err := r.ScanStruct(&v); err != nil {
+		t.Fatal(err)
+	} else if v.Id != 2 || v.Name != "b" || v.Comment == nil || *v.Comment != "comment" || v.Deleted == nil || !v.Deleted.Equal(now) {
+		t.Error("Unexpected row", v)
+	}
+	if err := r.ScanStruct(&v); err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+
+	// Scan into a map
+	r, err = conn.Query(Q("SELECT name, size AS bytes FROM test ORDER BY rowid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row, err := r.ScanMap(); err != nil {
+		t.Fatal(err)
+	} else if row["name"] != "a" || row["bytes"] != int64(100) {
+		t.Error("Unexpected row", row)
+	}
+
+	// Collect all rows, both values and pointers
+	var values []TestScanStructA
+	var ptrs []*TestScanStructA
+	if r, err := conn.Query(Q("SELECT * FROM test")); err != nil {
+		t.Fatal(err)
+	} else if err := r.ScanAll(&values); err != nil {
+		t.Error(err)
+	} else if len(values) != 2 || values[1].Name != "b" {
+		t.Error("Unexpected rows", values)
+	}
+	if r, err := conn.Query(Q("SELECT * FROM test")); err != nil {
+		t.Fatal(err)
+	} else if err := r.ScanAll(&ptrs); err != nil {
+		t.Error(err)
+	} else if len(ptrs) != 2 || ptrs[0].Size != 100 {
+		t.Error("Unexpected rows", ptrs)
+	}
+
+	// Types which cannot be scanned return an error
+	var bad struct {
+		Name []int64 `sqlite:"name"`
+	}
+	if r, err := conn.Query(Q("SELECT name FROM test")); err != nil {
+		t.Fatal(err)
+	} else if err := r.ScanStruct(&bad); err == nil {
+		t.Error("Expected error scanning text into slice")
+	}
+}
+
+func Test_Scan_002(t *testing.T) {
+	conn, err := sqlite3.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Scan times in the format of CURRENT_TIMESTAMP and dates
+	var v struct {
+		Created time.Time  `sqlite:"created"`
+		Date    *time.Time `sqlite:"date"`
+	}
+	r, err := conn.Query(Q("SELECT CURRENT_TIMESTAMP AS created, DATE('2021-02-03') AS date"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := r.ScanStruct(&v); err != nil {
+		t.Fatal(err)
+	} else if since := time.Since(v.Created); since < -time.Second || since > time.Minute {
+		t.Error("Unexpected created time", v.Created)
+	} else if v.Date == nil || !v.Date.Equal(time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Unexpected date", v.Date)
+	}
+}
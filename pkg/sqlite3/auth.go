@@ -1,9 +1,10 @@
 package sqlite3
 
 import (
-	// Modules
 	"context"
+	"errors"
 
+	// Modules
 	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
 
 	// Namespace Imports
@@ -14,6 +15,25 @@ import (
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE FUNCTIONS
 
+// authorize returns the result of an authorization request, which allows,
+// denies or (for reading a column) ignores an action. The column is then
+// read as NULL. Actions performed by the pool itself are always allowed.
+func (p *Pool) authorize(conn *Conn, action sqlite3.SQAction, args [4]string) sqlite3.SQAuth {
+	if conn.noauth {
+		return sqlite3.SQLITE_ALLOW
+	}
+	err := p.auth(conn.ctx, action, args)
+	switch {
+	case err == nil:
+		return sqlite3.SQLITE_ALLOW
+	case action == sqlite3.SQLITE_READ && errors.Is(err, ErrAuthMask):
+		return sqlite3.SQLITE_IGNORE
+	default:
+		p.err(err)
+		return sqlite3.SQLITE_DENY
+	}
+}
+
 func (p *Pool) auth(ctx context.Context, action sqlite3.SQAction, args [4]string) error {
 	switch action {
 	case sqlite3.SQLITE_CREATE_INDEX:
@@ -80,15 +100,25 @@ func (p *Pool) auth(ctx context.Context, action sqlite3.SQAction, args [4]string
 		case "COMMIT":
 			return p.cfg.Auth.CanTransaction(ctx, SQLITE_AUTH_TRANSACTION|SQLITE_AUTH_COMMIT)
 		}
-		// TODO: Op is BEGIN, ROLLBACK or COMMIT so use this
+	case sqlite3.SQLITE_SAVEPOINT: //             32   /* Operation       Savepoint Name  */
+		switch args[0] {
+		case "BEGIN":
+			return p.cfg.Auth.CanTransaction(ctx, SQLITE_AUTH_TRANSACTION|SQLITE_AUTH_SAVEPOINT|SQLITE_AUTH_BEGIN)
+		case "ROLLBACK":
+			return p.cfg.Auth.CanTransaction(ctx, SQLITE_AUTH_TRANSACTION|SQLITE_AUTH_SAVEPOINT|SQLITE_AUTH_ROLLBACK)
+		case "RELEASE":
+			return p.cfg.Auth.CanTransaction(ctx, SQLITE_AUTH_TRANSACTION|SQLITE_AUTH_SAVEPOINT|SQLITE_AUTH_COMMIT)
+		}
 	case sqlite3.SQLITE_READ: //                  20   /* Table Name      Column Name     */
 		return p.cfg.Auth.CanExec(ctx, SQLITE_AUTH_TABLE|SQLITE_AUTH_READ, args[2], args[0], args[1])
 	case sqlite3.SQLITE_UPDATE: //                23   /* Table Name      Column Name     */
 		return p.cfg.Auth.CanExec(ctx, SQLITE_AUTH_TABLE|SQLITE_AUTH_UPDATE, args[2], args[0], args[1])
-		// TODO		case sqlite3.SQLITE_SAVEPOINT: //             32   /* Operation       Savepoint Name  */
-		// TODO case sqlite3.SQLITE_ATTACH: //                24   /* Filename        NULL            */
-		// TODO case sqlite3.SQLITE_DETACH: //                25   /* Database Name   NULL            */
-		// TODO case sqlite3.SQLITE_REINDEX: //               27   /* Index Name      NULL            */
+	case sqlite3.SQLITE_ATTACH: //                24   /* Filename        NULL            */
+		return p.cfg.Auth.CanExec(ctx, SQLITE_AUTH_ATTACH, args[2], args[0])
+	case sqlite3.SQLITE_DETACH: //                25   /* Database Name   NULL            */
+		return p.cfg.Auth.CanExec(ctx, SQLITE_AUTH_DETACH, args[0])
+	case sqlite3.SQLITE_REINDEX: //               27   /* Index Name      NULL            */
+		return p.cfg.Auth.CanExec(ctx, SQLITE_AUTH_REINDEX, args[2], args[0])
 	}
 
 	// Report an error
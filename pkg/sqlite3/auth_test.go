@@ -9,6 +9,7 @@ import (
 	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
 
 	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
 	. "github.com/mutablelogic/go-sqlite"
 	. "github.com/mutablelogic/go-sqlite/pkg/lang"
 	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
@@ -49,6 +50,13 @@ func Test_Auth_001(t *testing.T) {
 	conn.(*Conn).Rollback()
 	conn.(*Conn).Begin(sqlite3.SQLITE_TXN_IMMEDIATE)
 	conn.(*Conn).Commit()
+
+	// Do a nested transaction
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		return txn.Do(context.Background(), 0, nil)
+	}); err != nil {
+		t.Error(err)
+	}
 }
 
 type Auth struct {
@@ -87,3 +95,169 @@ func (a *Auth) CanExec(_ context.Context, flag SQAuthFlag, schema string, args .
 	a.Logf("CanExec: %v %q %q", flag, schema, args)
 	return nil
 }
+
+func Test_Auth_002(t *testing.T) {
+	auth := &MaskAuth{NewAuth(t), 0}
+	cfg := NewConfig().WithAuth(auth)
+	pool, err := OpenPool(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Get connection
+	conn := pool.Get()
+	defer pool.Put(conn)
+
+	// Create a table with a secret column
+	if err := conn.(*Conn).Exec(Q("CREATE TABLE test (a TEXT, secret TEXT)"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.(*Conn).Exec(Q("INSERT INTO test VALUES ('a', 'b')"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.(*Conn).Exec(Q("CREATE INDEX test_a ON test (a)"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The secret column is read as NULL
+	r, err := conn.Query(Q("SELECT a, secret FROM test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row := r.Next(); len(row) != 2 {
+		t.Error("Unexpected row", row)
+	} else if row[0] != "a" || row[1] != nil {
+		t.Error("Unexpected row", row)
+	}
+
+	// Attach and reindex are denied
+	auth.deny = SQLITE_AUTH_ATTACH | SQLITE_AUTH_REINDEX
+	if err := conn.(*Conn).Attach("other", ":memory:"); err == nil {
+		t.Error("Expected attach to be denied")
+	}
+	if err := conn.(*Conn).Exec(Q("REINDEX test_a"), nil); err == nil {
+		t.Error("Expected reindex to be denied")
+	}
+}
+
+type MaskAuth struct {
+	*Auth
+	deny SQAuthFlag
+}
+
+// Mask the secret column, and deny any flags set
+func (a *MaskAuth) CanExec(ctx context.Context, flag SQAuthFlag, schema string, args ...string) error {
+	switch {
+	case flag.Is(SQLITE_AUTH_READ) && args[1] == "secret":
+		return ErrAuthMask
+	case flag.Is(a.deny):
+		return ErrBadParameter.With(flag)
+	default:
+		return a.Auth.CanExec(ctx, flag, schema, args...)
+	}
+}
+
+func Test_Auth_003(t *testing.T) {
+	pool, err := OpenPool(NewConfig().WithAuth(&ContextMaskAuth{NewAuth(t)}).WithCacheSize(10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn := pool.Get()
+	defer pool.Put(conn)
+	if err := conn.(*Conn).Exec(Q("CREATE TABLE test (a TEXT, secret TEXT)"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.(*Conn).Exec(Q("INSERT INTO test VALUES ('a', 'b')"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same cached statement is only masked for the masked context, both
+	// in transactions and outside of them
+	masked := context.WithValue(context.Background(), maskKey{}, true)
+	for _, ctx := range []context.Context{context.Background(), masked, context.Background(), masked} {
+		expected := interface{}("b")
+		if ctx == masked {
+			expected = nil
+		}
+		if err := conn.Do(ctx, 0, func(txn SQTransaction) error {
+			r, err := txn.Query(Q("SELECT a, secret FROM test"))
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			if row := r.Next(); len(row) != 2 || row[1] != expected {
+				t.Error("Unexpected row", row)
+			}
+			return nil
+		}); err != nil {
+			t.Error(err)
+		}
+		r, err := conn.QueryContext(ctx, Q("SELECT a, secret FROM test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row := r.Next(); len(row) != 2 || row[1] != expected {
+			t.Error("Unexpected row", row)
+		}
+		r.Close()
+	}
+}
+
+type maskKey struct{}
+
+type ContextMaskAuth struct {
+	*Auth
+}
+
+// Mask the secret column when the context has a mask key
+func (a *ContextMaskAuth) CanExec(ctx context.Context, flag SQAuthFlag, schema string, args ...string) error {
+	if flag.Is(SQLITE_AUTH_READ) && args[1] == "secret" && ctx != nil && ctx.Value(maskKey{}) != nil {
+		return ErrAuthMask
+	}
+	return a.Auth.CanExec(ctx, flag, schema, args...)
+}
+
+func Test_Auth_004(t *testing.T) {
+	auth := &TxnAuth{Auth: NewAuth(t)}
+	pool, err := OpenPool(NewConfig().WithAuth(auth), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn := pool.Get()
+	defer pool.Put(conn)
+
+	// Transactions and savepoints are reported with the transaction flag
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		return txn.Do(context.Background(), 0, func(SQTransaction) error {
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	savepoints := 0
+	for _, flag := range auth.flags {
+		if !flag.Is(SQLITE_AUTH_TRANSACTION) {
+			t.Error("Expected transaction flag:", flag)
+		}
+		if flag.Is(SQLITE_AUTH_SAVEPOINT) {
+			savepoints++
+		}
+	}
+	if savepoints != 2 {
+		t.Error("Unexpected savepoint operations:", auth.flags)
+	}
+}
+
+type TxnAuth struct {
+	*Auth
+	flags []SQAuthFlag
+}
+
+// Record the flags for each transaction operation
+func (a *TxnAuth) CanTransaction(ctx context.Context, flag SQAuthFlag) error {
+	a.flags = append(a.flags, flag)
+	return a.Auth.CanTransaction(ctx, flag)
+}
@@ -0,0 +1,200 @@
+package sqlite3
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// BackupOptions control how a database is copied by Backup and Restore
+type BackupOptions struct {
+	Pages    int                // Number of pages copied in each step, or zero for the default
+	Sleep    time.Duration      // Time to sleep between steps, so other connections can use the database
+	Restarts int                // Maximum number of restarts when the source changes, or zero for no limit
+	Vacuum   bool               // Backup with VACUUM INTO rather than copying pages
+	Progress BackupProgressFunc // Called after each step
+}
+
+// BackupProgressFunc is called after each step of a backup or restore with
+// the number of pages remaining and the total number of pages. A restart
+// is reported when the source changed and the copy started again.
+type BackupProgressFunc func(remaining, pages int, restart bool)
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultBackupPages = 100
+	backupTempExt      = ".tmp"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Backup copies a schema to a database file, replacing the file once the
+// copy is complete. Pages are copied in steps, and the copy starts again
+// when the source changes between steps. With the Vacuum option, the
+// database is copied in a single statement with VACUUM INTO.
+func (p *Pool) Backup(ctx context.Context, schema, path string, opts BackupOptions) error {
+	if schema == "" {
+		schema = DefaultSchema
+	}
+	if p.pathForSchema(schema) == "" {
+		return ErrNotFound.Withf("Backup: %q", schema)
+	}
+
+	// Check out a connection for the source
+	c, err := p.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Put(c)
+	conn := c.(*Conn)
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+	if samePath(conn.Filename(schema), path) {
+		return ErrBadParameter.Withf("Backup: %q is the source database", path)
+	}
+
+	// Copy into a temporary file, which replaces the destination on success
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*"+backupTempExt)
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if opts.Vacuum {
+		conn.noauth = true
+		err = conn.Exec(Q("VACUUM ", N(schema), " INTO ", V(tmp.Name())), nil)
+		conn.noauth = false
+	} else {
+		err = copyToPath(ctx, conn, schema, tmp.Name(), opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Return any errors from renaming
+	return os.Rename(tmp.Name(), path)
+}
+
+// Restore replaces the contents of a schema with a database file. Pages are
+// copied in steps, and other connections see the restored database once
+// the copy is complete. In WAL mode, the copy is made once any transaction
+// in progress has completed.
+func (p *Pool) Restore(ctx context.Context, schema, path string, opts BackupOptions) error {
+	if schema == "" {
+		schema = DefaultSchema
+	}
+	if p.pathForSchema(schema) == "" {
+		return ErrNotFound.Withf("Restore: %q", schema)
+	} else if opts.Vacuum {
+		return ErrBadParameter.With("Restore: vacuum is not supported")
+	} else if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	// Open the source database
+	src, err := OpenPath(path, SQFlag(sqlite3.SQLITE_OPEN_READONLY))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Copy into the writer in WAL mode, or a connection from the pool
+	if p.currentWriter() == nil {
+		c, err := p.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+		defer p.Put(c)
+		conn := c.(*Conn)
+		conn.Mutex.Lock()
+		defer conn.Mutex.Unlock()
+		return backupSteps(ctx, src, DefaultSchema, conn, schema, opts)
+	}
+	return p.Writer(func(conn *Conn) error {
+		return backupSteps(ctx, src, DefaultSchema, conn, schema, opts)
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// copyToPath copies a schema into a database file
+func copyToPath(ctx context.Context, conn *Conn, schema, path string, opts BackupOptions) error {
+	dest, err := OpenPath(path, SQFlag(sqlite3.SQLITE_OPEN_CREATE|sqlite3.SQLITE_OPEN_READWRITE))
+	if err != nil {
+		return err
+	}
+	if err := backupSteps(ctx, conn, schema, dest, DefaultSchema, opts); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
+
+// backupSteps copies pages from a source schema to a destination schema,
+// sleeping between steps and retrying when either database is locked
+func backupSteps(ctx context.Context, src *Conn, srcSchema string, dest *Conn, destSchema string, opts BackupOptions) error {
+	b, err := src.OpenBackup(dest.ConnEx.Conn, destSchema, srcSchema)
+	if err != nil {
+		return err
+	}
+
+	pages := opts.Pages
+	if pages == 0 {
+		pages = defaultBackupPages
+	}
+	copied, restarts := 0, 0
+	for {
+		err := b.Step(pages)
+		if err == sqlite3.SQLITE_DONE {
+			if opts.Progress != nil {
+				opts.Progress(0, b.PageCount(), false)
+			}
+			break
+		} else if err != nil && !IsRetryable(err) {
+			b.Finish()
+			return err
+		} else if err == nil {
+			// The number of pages copied does not increase when the source
+			// changed and the copy was restarted
+			restart := b.PageCount()-b.Remaining() <= copied
+			copied = b.PageCount() - b.Remaining()
+			if restart {
+				restarts++
+			}
+			if opts.Progress != nil {
+				opts.Progress(b.Remaining(), b.PageCount(), restart)
+			}
+			if restart && opts.Restarts > 0 && restarts > opts.Restarts {
+				b.Finish()
+				return ErrOutOfOrder.Withf("Backup: source changed %d times", restarts)
+			}
+		}
+
+		// Sleep between steps, or return if the context is cancelled
+		select {
+		case <-ctx.Done():
+			b.Finish()
+			return ctx.Err()
+		case <-time.After(opts.Sleep):
+		}
+	}
+
+	// Return any errors
+	return b.Finish()
+}
@@ -0,0 +1,133 @@
+package sqlite3_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+func Test_Backup_001(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+
+	dir := t.TempDir()
+	pool, err := OpenPool(NewConfig().WithSchema("main", filepath.Join(dir, "main.sqlite")).WithWAL(true), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Insert rows over many pages
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		if _, err := txn.Query(N("test").CreateTable(C("a"))); err != nil {
+			return err
+		}
+		for i := 0; i < 100; i++ {
+			if _, err := txn.Query(N("test").Insert("a"), strings.Repeat("x", 1000)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(conn)
+
+	// Backup in steps, changing the source once during the backup
+	steps, restarts := 0, 0
+	if err := pool.Backup(context.Background(), "", filepath.Join(dir, "backup.sqlite"), BackupOptions{
+		Pages: 5,
+		Progress: func(remaining, pages int, restart bool) {
+			if restart {
+				restarts++
+			}
+			if steps++; steps == 2 {
+				insert(t, pool, 1)
+			}
+		},
+	}); err != nil {
+		t.Fatal(err)
+	} else if steps < 5 {
+		t.Error("Unexpected number of steps", steps)
+	} else if restarts != 1 {
+		t.Error("Unexpected number of restarts", restarts)
+	} else if n := count(t, filepath.Join(dir, "backup.sqlite")); n != 101 {
+		t.Error("Unexpected count", n)
+	}
+
+	// Backup with VACUUM INTO
+	if err := pool.Backup(context.Background(), "main", filepath.Join(dir, "vacuum.sqlite"), BackupOptions{Vacuum: true}); err != nil {
+		t.Fatal(err)
+	} else if n := count(t, filepath.Join(dir, "vacuum.sqlite")); n != 101 {
+		t.Error("Unexpected count", n)
+	}
+	if err := pool.Backup(context.Background(), "main", filepath.Join(dir, "main.sqlite"), BackupOptions{}); err == nil {
+		t.Error("Expected error when backing up over the source")
+	}
+
+	// Restore the backup after adding rows
+	insert(t, pool, 10)
+	if err := pool.Restore(context.Background(), "main", filepath.Join(dir, "vacuum.sqlite"), BackupOptions{Pages: 5}); err != nil {
+		t.Fatal(err)
+	}
+	conn = pool.Get()
+	defer pool.Put(conn)
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		rs, err := txn.Query(Q("SELECT COUNT(*) FROM test"))
+		if err != nil {
+			return err
+		}
+		if row := rs.Next(); row == nil || row[0] != int64(101) {
+			t.Error("Unexpected count", row)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func insert(t *testing.T, pool *Pool, n int) {
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		for i := 0; i < n; i++ {
+			if _, err := txn.Query(N("test").Insert("a"), "y"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func count(t *testing.T, path string) int {
+	conn, err := OpenPath(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	rs, err := conn.Query(Q("SELECT COUNT(*) FROM test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row := rs.Next(); row == nil {
+		t.Fatal("Unexpected nil row")
+	} else {
+		return int(row[0].(int64))
+	}
+	return 0
+}
@@ -19,8 +19,11 @@ import (
 type ConnCache struct {
 	sync.Mutex
 	sync.Map
-	cap uint32 // Capacity of the cache, defaults to 100 prepared statements
-	n   uint32
+	cap  uint32 // Capacity of the cache, defaults to 100 prepared statements
+	n    uint32
+	refs map[*sqlite3.StatementEx]uint32 // Number of open results for each cached statement
+
+	hits, misses, evictions uint64 // Cache statistics
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -47,20 +50,29 @@ func (cache *ConnCache) SetCap(cap uint32) {
 	cache.cap = maxUnt32(0, cap)
 }
 
+// CacheStats returns the number of cache hits, misses and evictions
+func (cache *ConnCache) CacheStats() (uint64, uint64, uint64) {
+	return atomic.LoadUint64(&cache.hits), atomic.LoadUint64(&cache.misses), atomic.LoadUint64(&cache.evictions)
+}
+
 // Return a prepared statement from the cache, or prepare a new statement
-// and put it in the cache before returning
+// and put it in the cache before returning. A cached statement is not
+// evicted until the results are closed.
 func (cache *ConnCache) Prepare(conn *sqlite3.ConnEx, q string) (*Results, error) {
 	if conn == nil {
 		return nil, ErrInternalAppError
 	}
+
+	// Need a mutex around prepare
+	cache.Mutex.Lock()
+	defer cache.Mutex.Unlock()
 	st := cache.load(q)
 	if st == nil {
 		// Prepare a statement and store in cache
 		var err error
-
-		// Need a mutex around prepare
-		cache.Mutex.Lock()
-		defer cache.Mutex.Unlock()
+		if cache.cap > 0 {
+			atomic.AddUint64(&cache.misses, 1)
+		}
 		if st, err = conn.PrepareCached(q, cache.cap > 0); err != nil {
 			return nil, err
 		}
@@ -70,7 +82,17 @@ func (cache *ConnCache) Prepare(conn *sqlite3.ConnEx, q string) (*Results, error
 			cache.store(q, st)
 		}
 	}
-	return NewResults(st), nil
+
+	// Reference a cached statement until the results are closed
+	r := NewResults(st)
+	if st.Cached() {
+		if cache.refs == nil {
+			cache.refs = make(map[*sqlite3.StatementEx]uint32)
+		}
+		cache.refs[st]++
+		r.cache = cache
+	}
+	return r, nil
 }
 
 // Close all conn cache prepared statements
@@ -93,11 +115,67 @@ func (cache *ConnCache) Close() error {
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// store a statement in the cache, evicting the least recently used
+// statements if the cache exceeds capacity. The caller must hold the lock.
 func (cache *ConnCache) store(key string, st *sqlite3.StatementEx) {
 	cache.Map.Store(key, st)
-	if n := atomic.AddUint32(&cache.n, 1); n > cache.cap {
-		fmt.Println("TODO: Cached statements exceeeds cap", n)
+	atomic.AddUint32(&cache.n, 1)
+	cache.trim(key)
+}
+
+// release drops a reference to a cached statement when results are closed,
+// and evicts statements if the cache exceeds capacity
+func (cache *ConnCache) release(st *sqlite3.StatementEx) {
+	cache.Mutex.Lock()
+	defer cache.Mutex.Unlock()
+	if n := cache.refs[st]; n > 1 {
+		cache.refs[st] = n - 1
+	} else {
+		delete(cache.refs, st)
+	}
+	cache.trim("")
+}
+
+// trim evicts the least recently used statements while the cache exceeds
+// capacity, except for the statement with key except. The caller must hold
+// the lock.
+func (cache *ConnCache) trim(except string) {
+	for n := atomic.LoadUint32(&cache.n); n > cache.cap; n = atomic.LoadUint32(&cache.n) {
+		if !cache.evict(except) {
+			break
+		}
+	}
+}
+
+// evict finalizes the least recently used statement which is not in use
+// and has no open results, except for the statement with key except. Returns
+// false if no statement could be evicted. The caller must hold the lock.
+func (cache *ConnCache) evict(except string) bool {
+	var lru string
+	var ts int64
+	cache.Map.Range(func(key, value interface{}) bool {
+		st := value.(*sqlite3.StatementEx)
+		if key.(string) == except || cache.refs[st] > 0 || st.IsBusy() {
+			return true
+		}
+		if lru == "" || st.Timestamp() < ts {
+			lru, ts = key.(string), st.Timestamp()
+		}
+		return true
+	})
+	if lru == "" {
+		return false
+	}
+
+	// Remove from the cache and finalize
+	if st, exists := cache.Map.LoadAndDelete(lru); exists {
+		atomic.AddUint32(&cache.n, ^uint32(0))
+		atomic.AddUint64(&cache.evictions, 1)
+		if err := st.(*sqlite3.StatementEx).Close(); err != nil {
+			return false
+		}
 	}
+	return true
 }
 
 func (cache *ConnCache) load(key string) *sqlite3.StatementEx {
@@ -112,6 +190,7 @@ func (cache *ConnCache) load(key string) *sqlite3.StatementEx {
 	} else {
 		// Increment "used" counter by one
 		st.Inc(1)
+		atomic.AddUint64(&cache.hits, 1)
 		// Report if higher than capacity
 		return st
 	}
@@ -2,9 +2,11 @@ package sqlite3_test
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"sync"
 	"testing"
+	"time"
 
 	// Module imports
 	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
@@ -30,8 +32,8 @@ func Test_Cache_001(t *testing.T) {
 		for i := 0; i < 1000; i++ {
 			wg.Add(1)
 			go func() {
-				txn.Lock()
-				defer txn.Unlock()
+				txn.(*Txn).Lock()
+				defer txn.(*Txn).Unlock()
 				defer wg.Done()
 				n := rand.Uint32() % 10
 				r, err := txn.Query(Q("SELECT ", n))
@@ -65,3 +67,162 @@ func Test_Cache_001(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func Test_Cache_002(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+	pool, err := OpenPool(NewConfig().WithCacheSize(2), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+
+	// Prepare five different statements, keeping the first in use
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		busy, err := txn.Query(Q("SELECT 1 UNION ALL SELECT 2"))
+		if err != nil {
+			return err
+		}
+		for i := 2; i <= 5; i++ {
+			r, err := txn.Query(Q("SELECT ", i))
+			if err != nil {
+				return err
+			}
+			r.Close()
+		}
+		// The busy statement should not have been evicted
+		if row := busy.Next(); row == nil {
+			t.Error("Unexpected nil row from busy statement")
+		}
+		return busy.Close()
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check evictions
+	hits, misses, evictions := conn.(*Conn).CacheStats()
+	if hits != 0 || misses != 5 || evictions != 3 {
+		t.Error("Unexpected cache stats", hits, misses, evictions)
+	}
+}
+
+func Test_Cache_003(t *testing.T) {
+	conn, err := OpenPath(":memory:", SQFlag(sqlite3.DefaultFlags)|SQLITE_OPEN_CACHE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Query outside of a transaction
+	if _, err := conn.Query(N("test").CreateTable(C("a").WithType("INTEGER"))); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := conn.Query(Q("INSERT INTO test (a) VALUES (?)"), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := conn.Query(Q("SELECT COUNT(*) FROM test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if row := r.Next(); len(row) != 1 || row[0] != int64(3) {
+		t.Error("Unexpected row", row)
+	}
+
+	// Insert statement should have been cached
+	if hits, _, _ := conn.CacheStats(); hits != 2 {
+		t.Error("Unexpected cache hits", hits)
+	}
+}
+
+func Test_Cache_004(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+	pool, err := OpenPool(NewConfig().WithCacheSize(2), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+
+	// Execute a statement which returns no rows, so it is no longer busy,
+	// and keep the results open while other statements are prepared
+	held, err := conn.(*Conn).Query(Q("SELECT 1 AS a WHERE 0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i <= 5; i++ {
+		r, err := conn.(*Conn).Query(Q("SELECT ", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+	}
+
+	// The statement should not have been evicted while the results are open
+	if cols := held.Columns(); len(cols) != 1 || cols[0].Name() != "a" {
+		t.Error("Unexpected columns", cols)
+	}
+	if _, _, evictions := conn.(*Conn).CacheStats(); evictions != 3 {
+		t.Error("Unexpected evictions", evictions)
+	}
+
+	// Closing the results allows the statement to be evicted
+	if err := held.Close(); err != nil {
+		t.Error(err)
+	}
+	if r, err := conn.(*Conn).Query(Q("SELECT 6")); err != nil {
+		t.Error(err)
+	} else {
+		r.Close()
+	}
+	if _, _, evictions := conn.(*Conn).CacheStats(); evictions != 4 {
+		t.Error("Unexpected evictions", evictions)
+	}
+}
+
+func Test_Cache_005(t *testing.T) {
+	conn, err := OpenPath(":memory:", SQFlag(sqlite3.DefaultFlags)|SQLITE_OPEN_CACHE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A cancelled context returns an error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := conn.QueryContext(ctx, Q("SELECT 1")); !errors.Is(err, context.Canceled) {
+		t.Error("Expected context.Canceled, got", err)
+	}
+
+	// A long running query is interrupted when the deadline expires
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	now := time.Now()
+	if _, err := conn.QueryContext(ctx, Q("WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c) SELECT COUNT(*) FROM c")); err == nil {
+		t.Error("Expected query to be interrupted")
+	} else if since := time.Since(now); since > time.Second {
+		t.Error("Query was interrupted after", since)
+	}
+
+	// The connection can be used once the results are closed
+	r, err := conn.QueryContext(context.Background(), Q("SELECT 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if row := r.Next(); len(row) != 1 || row[0] != int64(1) {
+		t.Error("Unexpected row", row)
+	}
+}
@@ -2,6 +2,7 @@ package sqlite3
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	// Modules
 	multierror "github.com/hashicorp/go-multierror"
@@ -29,15 +31,47 @@ type Conn struct {
 	ConnCache
 
 	counter int64
+	txn     int64
 	c       chan struct{}
 	f       SQFlag
 	ctx     context.Context
+	writer  *Conn     // Transactions which write are performed on this connection
+	opened  time.Time // Time the connection was opened
+	idle    time.Time // Time the connection was returned to a pool
+	retry   RetryPolicy
+	schemas int64 // Generation of the pool schemas which are attached
+	noauth  bool  // Set when the pool is changing the connection, which is not authorized
+
+	// Authorization
+	authfn   sqlite3.AuthorizerHookFunc // Authorizer set for the connection, or nil
+	authctx  context.Context            // Context which cached statements were last authorized with
+	authpool bool                       // Set when cached statements were last authorized for the pool
+	readonly bool                       // Set in a read-only transaction, so query_only cannot be changed
+
+	// Tracing
+	tmu     sync.Mutex
+	tracefn TraceFunc
+	tracer  Tracer
+	spans   map[*sqlite3.Statement]*Span
+	pending []*Span
+
+	// Change notification
+	changefn  ChangeFunc
+	changes   []ChangeEvent
+	committed []ChangeEvent
+
+	// Transaction callbacks
+	rollback   bool
+	oncommit   []func()
+	onrollback []func()
+	rolledback []func() // Rollback functions of savepoints which have been rolled back
 }
 
 type Txn struct {
 	sync.Mutex
 	*Conn
-	f SQFlag
+	f     SQFlag
+	depth int // Savepoint nesting depth, zero for the outermost transaction
 }
 
 type ExecFunc sqlite3.ExecFunc
@@ -47,7 +81,8 @@ type TxnFunc func(SQTransaction) error
 // GLOBALS
 
 var (
-	counter int64
+	counter    int64
+	txncounter int64
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -69,6 +104,7 @@ func New(flags ...SQFlag) (*Conn, error) {
 func OpenPath(path string, flags SQFlag) (*Conn, error) {
 	conn := new(Conn)
 	conn.counter = atomic.AddInt64(&counter, 1)
+	conn.opened = time.Now()
 
 	// If no create flag then check to make sure database exists
 	if path != defaultMemory && flags&SQFlag(sqlite3.SQLITE_OPEN_MEMORY) == 0 && SQFlag(sqlite3.SQLITE_OPEN_CREATE) == 0 {
@@ -103,6 +139,11 @@ func OpenPath(path string, flags SQFlag) (*Conn, error) {
 		conn.SetCap(0)
 	}
 
+	// Set commit, rollback and authorizer hooks
+	conn.ConnEx.SetCommitHook(conn.commitHook)
+	conn.ConnEx.SetRollbackHook(conn.rollbackHook)
+	conn.ConnEx.SetAuthorizerHook(conn.authorize)
+
 	// Set foreign keys
 	if flags&SQLITE_OPEN_FOREIGNKEYS != 0 {
 		if err := conn.SetForeignKeyConstraints(true); err != nil {
@@ -148,7 +189,7 @@ func (conn *Conn) Close() error {
 func (conn *Conn) String() string {
 	str := "<conn"
 	str += fmt.Sprint(" counter=", conn.counter)
-	str += fmt.Sprint(" cache=", conn.ConnCache)
+	str += fmt.Sprint(" cache=", &conn.ConnCache)
 	str += fmt.Sprint(" conn=", conn.ConnEx)
 
 	return str + ">"
@@ -163,52 +204,167 @@ func (conn *Conn) Exec(st SQStatement, fn SQExecFunc) error {
 	if st == nil {
 		return ErrBadParameter.With("Exec")
 	}
-	return conn.ConnEx.Exec(st.Query(), sqlite3.ExecFunc(fn))
+	err := conn.ConnEx.Exec(st.Query(), sqlite3.ExecFunc(fn))
+	conn.traceFlush(err)
+	conn.notify(err)
+	return err
 }
 
-// Execute SQL statement outside of transaction - currently not implemented
+// Query executes a statement outside of a transaction, using the statement
+// cache, and returns the results. Each statement is committed as soon as it
+// completes. Results are iterated in the same way as for a transaction.
 func (conn *Conn) Query(st SQStatement, v ...interface{}) (SQResults, error) {
-	return nil, ErrNotImplemented.With("Query")
+	return conn.QueryContext(context.Background(), st, v...)
+}
+
+// QueryContext executes a statement outside of a transaction in the same way
+// as Query. The connection is locked whilst the statement is prepared and
+// stepped, and a statement in progress is interrupted when the context is done.
+// It should not be called from within a transaction on the same connection.
+func (conn *Conn) QueryContext(ctx context.Context, st SQStatement, v ...interface{}) (SQResults, error) {
+	if st == nil {
+		return nil, ErrBadParameter.With("QueryContext")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	} else if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// Get a results object, authorizing the statement with the context
+	conn.Mutex.Lock()
+	conn.ctx = ctx
+	r, err := conn.prepare(st)
+	conn.ctx = nil
+	conn.Mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute first query, which notifies any committed changes
+	r.conn, r.mu, r.ctx = conn, &conn.Mutex, ctx
+	if err := r.NextQuery(v...); err != nil {
+		r.Close()
+		return nil, err
+	} else {
+		return r, nil
+	}
 }
 
-// Perform a transaction, rollback if error is returned
+// Perform a transaction, rollback if error is returned. If the transaction
+// fails because the database is busy or locked, it is attempted again
+// according to the retry policy. Unless the SQLITE_TXN_DEFAULT,
+// SQLITE_TXN_EXCLUSIVE or SQLITE_TXN_READONLY flags are set, an immediate
+// transaction is started, so that write locks are acquired at the start of
+// the transaction rather than when upgrading from a read lock.
 func (conn *Conn) Do(ctx context.Context, flag SQFlag, fn func(SQTransaction) error) error {
+	// Route transactions which are not read-only to the writer
+	if conn.writer != nil && !flag.Is(SQLITE_TXN_READONLY) {
+		return conn.writer.Do(ctx, flag, fn)
+	}
+
+	// Perform the transaction until it succeeds or cannot be retried. Commit
+	// or rollback functions registered by an attempt which is retried are
+	// discarded, as the function registers them again on the next attempt
+	for attempt := 1; ; attempt++ {
+		fns, err := conn.attempt(ctx, flag, fn)
+		if err == nil || !conn.retry.wait(ctx, attempt, err) {
+			for _, fn := range fns {
+				fn()
+			}
+			return err
+		}
+	}
+}
+
+// SetRetryPolicy sets the policy for retrying transactions which fail
+// because the database is busy or locked
+func (conn *Conn) SetRetryPolicy(r RetryPolicy) {
+	conn.retry = r
+}
+
+// do performs a transaction once, and then calls the commit or rollback
+// functions once the connection is unlocked, so they can use the connection
+func (conn *Conn) do(ctx context.Context, flag SQFlag, fn func(SQTransaction) error) error {
+	fns, err := conn.attempt(ctx, flag, fn)
+	for _, fn := range fns {
+		fn()
+	}
+	return err
+}
+
+// attempt performs a transaction once, and returns the commit or rollback
+// functions to call once the connection is unlocked
+func (conn *Conn) attempt(ctx context.Context, flag SQFlag, fn func(SQTransaction) error) ([]func(), error) {
 	conn.Mutex.Lock()
 	defer conn.Mutex.Unlock()
 
 	// Return any context errors
 	if ctx != nil && ctx.Err() != nil {
-		return ctx.Err()
+		return nil, ctx.Err()
 	}
 
 	// Get existing foreign key constraints, set new ones
-	fk, err := conn.ForeignKeyConstraints()
-	if err != nil {
-		return err
-	}
-	if flag&SQLITE_TXN_NO_FOREIGNKEY_CONSTRAINTS != 0 && fk {
-		if err := conn.SetForeignKeyConstraints(false); err != nil {
+	var fk bool
+	if err := conn.internal(func() (err error) {
+		if fk, err = conn.ForeignKeyConstraints(); err != nil {
 			return err
+		} else if flag&SQLITE_TXN_NO_FOREIGNKEY_CONSTRAINTS != 0 && fk {
+			return conn.SetForeignKeyConstraints(false)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// Prevent changes in read-only transactions
+	if flag.Is(SQLITE_TXN_READONLY) {
+		var qo bool
+		if err := conn.internal(func() (err error) {
+			if qo, err = conn.QueryOnly(); err != nil {
+				return err
+			}
+			return conn.SetQueryOnly(true)
+		}); err != nil {
+			return nil, err
 		}
+		defer conn.internal(func() error {
+			return conn.SetQueryOnly(qo)
+		})
+
+		// Deny changes to query_only. Changing query_only expires all
+		// prepared statements, so any cached statement which changes it is
+		// authorized again when executed
+		conn.readonly = true
+		defer func() { conn.readonly = false }()
 	}
 
 	// Transaction flags (UGLY!)
-	v := sqlite3.SQLITE_TXN_DEFAULT
+	v := sqlite3.SQLITE_TXN_IMMEDIATE
 	if flag.Is(SQLITE_TXN_EXCLUSIVE) {
 		v = sqlite3.SQLITE_TXN_EXCLUSIVE
 	} else if flag.Is(SQLITE_TXN_IMMEDIATE) {
 		v = sqlite3.SQLITE_TXN_IMMEDIATE
+	} else if flag.Is(SQLITE_TXN_DEFAULT | SQLITE_TXN_READONLY) {
+		v = sqlite3.SQLITE_TXN_DEFAULT
+	} else if conn.f.Is(SQFlag(sqlite3.SQLITE_OPEN_READONLY)) {
+		v = sqlite3.SQLITE_TXN_DEFAULT
 	}
 
-	// Begin transaction
+	// Begin transaction, which is authorized with the context of the
+	// transaction until it is committed or rolled back
+	conn.rollback = false
+	conn.txn = atomic.AddInt64(&txncounter, 1)
+	conn.ctx = ctx
+	defer func() { conn.ctx = nil }()
 	if err := conn.ConnEx.Begin(v); err != nil {
-		return err
+		conn.txn = 0
+		return nil, err
 	}
 
 	// Perform transaction
 	var result error
 	if fn != nil {
-		conn.ctx = ctx
 		conn.SetProgressHandler(100, func() bool {
 			return ctx != nil && ctx.Err() != nil
 		})
@@ -216,29 +372,40 @@ func (conn *Conn) Do(ctx context.Context, flag SQFlag, fn func(SQTransaction) er
 			result = multierror.Append(result, err)
 		}
 		conn.SetProgressHandler(0, nil)
-		conn.ctx = nil
 	}
 
-	// Commit or rollback transaction
+	// Commit transaction, or rollback if the commit fails
 	if result == nil {
-		if err := conn.ConnEx.Commit(); err != nil {
+		err := conn.ConnEx.Commit()
+		conn.traceFlush(err)
+		conn.notify(err)
+		if err != nil {
 			result = multierror.Append(result, err)
 		}
-	} else {
-		if err := conn.ConnEx.Rollback(); err != nil {
+	}
+	if result != nil && !conn.ConnEx.Autocommit() {
+		err := conn.ConnEx.Rollback()
+		conn.traceFlush(err)
+		if err != nil {
 			result = multierror.Append(result, err)
 		}
 	}
+	conn.txn = 0
+
+	// Get commit or rollback functions
+	fns := conn.callbacks(result == nil && !conn.rollback)
 
 	// Return foreign key constraints to previous value
 	if flag&SQLITE_TXN_NO_FOREIGNKEY_CONSTRAINTS != 0 {
-		if err := conn.SetForeignKeyConstraints(fk); err != nil {
+		if err := conn.internal(func() error {
+			return conn.SetForeignKeyConstraints(fk)
+		}); err != nil {
 			result = multierror.Append(result, err)
 		}
 	}
 
-	// Return any errors
-	return result
+	// Return the functions and any errors
+	return fns, result
 }
 
 // Attach database as schema. If path is empty then a new in-memory database
@@ -285,6 +452,14 @@ func (conn *Conn) Detach(schema string) error {
 	return conn.ConnEx.Exec("DETACH DATABASE "+QuoteIdentifier(schema), nil)
 }
 
+// SetAuthorizerHook sets a function which authorizes each action when a
+// statement is prepared, or nil to allow all actions. Changes to query_only
+// are always denied in a read-only transaction.
+func (conn *Conn) SetAuthorizerHook(fn sqlite3.AuthorizerHookFunc) error {
+	conn.authfn = fn
+	return conn.ConnEx.SetAuthorizerHook(conn.authorize)
+}
+
 // Flags returns the Open Flags
 func (c *Conn) Flags() SQFlag {
 	return c.f
@@ -295,9 +470,37 @@ func (c *Conn) Counter() int64 {
 	return c.counter
 }
 
+// Txn returns unique transaction counter, or zero if not in a transaction
+func (c *Conn) Txn() int64 {
+	return c.txn
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS - TRANSACTIONS
 
+// OnCommit registers a function to be called once the current transaction
+// has been committed and the connection unlocked. Outside of a transaction,
+// the function is called immediately.
+func (conn *Conn) OnCommit(fn func()) {
+	if fn == nil {
+		return
+	} else if conn.txn == 0 {
+		fn()
+	} else {
+		conn.oncommit = append(conn.oncommit, fn)
+	}
+}
+
+// OnRollback registers a function to be called once the current transaction
+// has been rolled back. Outside of a transaction, the function is never called.
+// When a transaction is retried, only the functions registered by the final
+// attempt are called.
+func (conn *Conn) OnRollback(fn func()) {
+	if fn != nil && conn.txn != 0 {
+		conn.onrollback = append(conn.onrollback, fn)
+	}
+}
+
 // Execute SQL statement and invoke a callback for each row of results which may return true to abort
 func (txn *Txn) Query(st SQStatement, v ...interface{}) (SQResults, error) {
 	if st == nil {
@@ -305,19 +508,99 @@ func (txn *Txn) Query(st SQStatement, v ...interface{}) (SQResults, error) {
 	}
 
 	// Get a results object
-	r, err := txn.Conn.ConnCache.Prepare(txn.Conn.ConnEx, st.Query())
+	r, err := txn.Conn.prepare(st)
 	if err != nil {
 		return nil, err
 	}
 
 	// Execute first query
-	if err := r.NextQuery(v...); err != nil {
+	r.conn = txn.Conn
+	if err := r.NextQuery(v...); errors.Is(err, sqlite3.SQLITE_READONLY) && txn.f.Is(SQLITE_TXN_READONLY) {
+		r.Close()
+		return nil, sqlite3.SQLITE_READONLY.With("Cannot modify the database in a read-only transaction")
+	} else if err != nil {
+		r.Close()
 		return nil, err
 	} else {
 		return r, nil
 	}
 }
 
+// Do performs a nested transaction within a transaction by creating a
+// savepoint. The savepoint is released if the function returns nil, or rolled
+// back to on any error or cancelled context, leaving the outer transaction
+// intact. The flags are ignored, as the transaction type is determined by
+// the outermost transaction.
+func (txn *Txn) Do(ctx context.Context, flag SQFlag, fn func(SQTransaction) error) error {
+	// Return any context errors
+	if ctx != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// Record state which is restored on rollback
+	conn := txn.Conn
+	changes, oncommit, onrollback := len(conn.changes), len(conn.oncommit), len(conn.onrollback)
+
+	// Create savepoint
+	name := QuoteIdentifier(fmt.Sprint(savepointPrefix, txn.depth+1))
+	if err := conn.ConnEx.Exec("SAVEPOINT "+name, nil); err != nil {
+		conn.traceFlush(err)
+		return err
+	}
+
+	// Perform nested transaction, cancelling on either context
+	var result error
+	if fn != nil {
+		outer := conn.ctx
+		conn.ctx = ctx
+		conn.SetProgressHandler(100, func() bool {
+			return (ctx != nil && ctx.Err() != nil) || (outer != nil && outer.Err() != nil)
+		})
+		if err := fn(&Txn{Conn: conn, f: txn.f, depth: txn.depth + 1}); err != nil {
+			result = multierror.Append(result, err)
+		}
+		conn.SetProgressHandler(100, func() bool {
+			return outer != nil && outer.Err() != nil
+		})
+		conn.ctx = outer
+	}
+
+	// Release the savepoint, or rollback to the savepoint and then release it.
+	// If sqlite has already rolled back the outer transaction, there is no
+	// savepoint to rollback to
+	if result == nil {
+		err := conn.ConnEx.Exec("RELEASE "+name, nil)
+		conn.traceFlush(err)
+		if err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	if result != nil && !conn.ConnEx.Autocommit() {
+		err := conn.ConnEx.Exec("ROLLBACK TO "+name+"; RELEASE "+name, nil)
+		conn.traceFlush(err)
+		if err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	// On rollback, drop any changes and commit functions registered within
+	// the savepoint, and queue the rollback functions, which are called once
+	// the connection is unlocked
+	if result != nil && len(conn.changes) >= changes {
+		conn.changes = conn.changes[:changes]
+	}
+	if result != nil && len(conn.oncommit) >= oncommit {
+		conn.oncommit = conn.oncommit[:oncommit]
+	}
+	if result != nil && len(conn.onrollback) >= onrollback {
+		conn.rolledback = append(conn.rolledback, conn.onrollback[onrollback:]...)
+		conn.onrollback = conn.onrollback[:onrollback]
+	}
+
+	// Return any errors
+	return result
+}
+
 // Flags returns the Open Flags or'd with Transaction Flags
 func (t *Txn) Flags() SQFlag {
 	return t.f | t.Conn.f
@@ -340,3 +623,76 @@ func (conn *Conn) attachCreate(path string) error {
 		return nil
 	}
 }
+
+// internal calls a function which executes statements on behalf of the pool
+// rather than the caller, which are not authorized
+func (conn *Conn) internal(fn func() error) error {
+	noauth := conn.noauth
+	conn.noauth = true
+	defer func() { conn.noauth = noauth }()
+	return fn()
+}
+
+// prepare returns the results for a statement, using the statement cache
+func (conn *Conn) prepare(st SQStatement) (*Results, error) {
+	if err := conn.reauthorize(); err != nil {
+		return nil, err
+	}
+	return conn.ConnCache.Prepare(conn.ConnEx, st.Query())
+}
+
+// reauthorize expires all statements when the context has changed since
+// statements were last authorized. Statements are only authorized when they
+// are prepared, so resetting the authorizer means cached statements are
+// prepared and authorized again for the new context when next executed.
+func (conn *Conn) reauthorize() error {
+	if conn.authfn == nil || (conn.ctx == conn.authctx && conn.noauth == conn.authpool) {
+		return nil
+	}
+	if err := conn.ConnEx.SetAuthorizerHook(conn.authorize); err != nil {
+		return err
+	}
+	conn.authctx, conn.authpool = conn.ctx, conn.noauth
+	return nil
+}
+
+// authorize denies changes to query_only in a read-only transaction, and
+// then calls the authorizer set for the connection
+func (conn *Conn) authorize(action sqlite3.SQAction, args [4]string) sqlite3.SQAuth {
+	if action == sqlite3.SQLITE_PRAGMA && args[1] != "" && strings.EqualFold(args[0], "query_only") && conn.readonly && !conn.noauth {
+		return sqlite3.SQLITE_DENY
+	}
+	if conn.authfn != nil {
+		return conn.authfn(action, args)
+	}
+	return sqlite3.SQLITE_ALLOW
+}
+
+// commitHook is called by sqlite before a transaction is committed, and
+// moves any changes to the list of committed changes
+func (conn *Conn) commitHook() bool {
+	conn.committed = append(conn.committed, conn.changes...)
+	conn.changes = conn.changes[:0]
+	return false
+}
+
+// rollbackHook is called by sqlite when a transaction is rolled back, either
+// explicitly or by an error, and drops any changes
+func (conn *Conn) rollbackHook() {
+	conn.changes = conn.changes[:0]
+	conn.committed = conn.committed[:0]
+	conn.rollback = true
+}
+
+// callbacks returns the rollback functions of any savepoints which were
+// rolled back, and the commit or rollback functions registered during a
+// transaction, then clears them
+func (conn *Conn) callbacks(commit bool) []func() {
+	fns := conn.onrollback
+	if commit {
+		fns = conn.oncommit
+	}
+	fns = append(conn.rolledback, fns...)
+	conn.oncommit, conn.onrollback, conn.rolledback = nil, nil, nil
+	return fns
+}
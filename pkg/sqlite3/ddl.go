@@ -0,0 +1,410 @@
+package sqlite3
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	// Namespace imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/quote"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// ddltoken is a token from a CREATE statement, with offsets into the
+// statement so that expressions can be extracted verbatim
+type ddltoken struct {
+	kind  rune   // ddlWord, ddlIdentifier, ddlString or a punctuation character
+	value string // Token value, with any quotes removed
+	pos   int    // Offset of the first character
+	end   int    // Offset after the last character
+}
+
+// ddltable contains the parts of a CREATE TABLE statement which are not
+// available through pragmas
+type ddltable struct {
+	checks       []string          // CHECK constraint expressions
+	generated    map[string]string // Generated column expressions
+	withoutrowid bool
+	strict       bool
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	ddlWord       = 'w'
+	ddlIdentifier = 'i'
+	ddlString     = 's'
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// ddlTokenize splits a statement into tokens, skipping whitespace and comments.
+// The tokenizer package is not used, as it splits quoted strings into words
+// and does not return the offsets needed to extract expressions
+func ddlTokenize(sql string) []ddltoken {
+	result := []ddltoken{}
+	for i := 0; i < len(sql); {
+		ch := sql[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f':
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			if j := strings.IndexByte(sql[i:], '\n'); j < 0 {
+				i = len(sql)
+			} else {
+				i += j + 1
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			if j := strings.Index(sql[i+2:], "*/"); j < 0 {
+				i = len(sql)
+			} else {
+				i += j + 4
+			}
+		case ch == '\'':
+			j, value := ddlQuoted(sql, i, '\'')
+			result = append(result, ddltoken{ddlString, value, i, j})
+			i = j
+		case ch == '"' || ch == '`':
+			j, value := ddlQuoted(sql, i, rune(ch))
+			result = append(result, ddltoken{ddlIdentifier, value, i, j})
+			i = j
+		case ch == '[':
+			j := strings.IndexByte(sql[i:], ']')
+			if j < 0 {
+				j = len(sql) - i - 1
+			}
+			result = append(result, ddltoken{ddlIdentifier, sql[i+1 : i+j], i, i + j + 1})
+			i += j + 1
+		case isWordByte(ch):
+			j := i
+			for j < len(sql) && isWordByte(sql[j]) {
+				j++
+			}
+			result = append(result, ddltoken{ddlWord, sql[i:j], i, j})
+			i = j
+		default:
+			r, size := utf8.DecodeRuneInString(sql[i:])
+			result = append(result, ddltoken{r, string(r), i, i + size})
+			i += size
+		}
+	}
+	return result
+}
+
+// ddlQuoted returns the offset after a quoted string starting at pos and
+// the unquoted value, where doubled quotes are escaped quotes
+func ddlQuoted(sql string, pos int, quote rune) (int, string) {
+	q := string(quote)
+	value := ""
+	for i := pos + 1; i < len(sql); {
+		j := strings.Index(sql[i:], q)
+		if j < 0 {
+			break
+		}
+		value += sql[i : i+j]
+		i += j + 1
+		if strings.HasPrefix(sql[i:], q) {
+			value += q
+			i++
+		} else {
+			return i, value
+		}
+	}
+	return len(sql), value
+}
+
+// isWordByte returns true if a byte can be part of a keyword, bare identifier
+// or number
+func isWordByte(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= 0x80 || (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// isKeyword returns true if the token at position i is one of the keywords
+func isKeyword(tokens []ddltoken, i int, keywords ...string) bool {
+	if i < 0 || i >= len(tokens) || tokens[i].kind != ddlWord {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(tokens[i].value, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// ddlClose returns the index of the token which closes the parenthesis at
+// position i, or -1 if the parenthesis is not closed
+func ddlClose(tokens []ddltoken, i int) int {
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j].kind {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// ddlExpr returns the expression within the parenthesis at position i and
+// the index of the closing parenthesis
+func ddlExpr(sql string, tokens []ddltoken, i int) (string, int) {
+	if i >= len(tokens) || tokens[i].kind != '(' {
+		return "", -1
+	}
+	j := ddlClose(tokens, i)
+	if j < 0 {
+		return "", -1
+	}
+	return strings.TrimSpace(sql[tokens[i].end:tokens[j].pos]), j
+}
+
+// ddlParseTable returns the CHECK constraints, generated column expressions
+// and table options from a CREATE TABLE statement, or nil if the statement
+// does not contain column definitions
+func ddlParseTable(sql string) *ddltable {
+	tokens := ddlTokenize(sql)
+
+	// Find the column definitions
+	open := -1
+	for i, token := range tokens {
+		if token.kind == '(' {
+			open = i
+			break
+		} else if isKeyword(tokens, i, "AS") {
+			return nil
+		}
+	}
+	if open < 0 {
+		return nil
+	}
+	end := ddlClose(tokens, open)
+	if end < 0 {
+		return nil
+	}
+
+	// Split the definitions on commas, skipping anything within parentheses
+	result := &ddltable{generated: make(map[string]string)}
+	for i := open + 1; i < end; i++ {
+		// Table constraints start with a keyword, column definitions with the name
+		name := ""
+		if !isKeyword(tokens, i, "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN") {
+			name = tokens[i].value
+			i++
+		}
+		for ; i < end && tokens[i].kind != ','; i++ {
+			switch {
+			case isKeyword(tokens, i, "CHECK"):
+				if expr, j := ddlExpr(sql, tokens, i+1); j > 0 {
+					result.checks = append(result.checks, expr)
+					i = j
+				}
+			case isKeyword(tokens, i, "AS") && name != "":
+				if expr, j := ddlExpr(sql, tokens, i+1); j > 0 {
+					result.generated[name] = expr
+					i = j
+				}
+			case tokens[i].kind == '(':
+				if j := ddlClose(tokens, i); j > 0 {
+					i = j
+				}
+			}
+		}
+	}
+
+	// Set the table options
+	for i := end + 1; i < len(tokens); i++ {
+		switch {
+		case isKeyword(tokens, i, "WITHOUT") && isKeyword(tokens, i+1, "ROWID"):
+			result.withoutrowid = true
+		case isKeyword(tokens, i, "STRICT"):
+			result.strict = true
+		}
+	}
+
+	// Return success
+	return result
+}
+
+// ddlParseTrigger returns a trigger from a CREATE TRIGGER statement, or nil
+// if the statement could not be parsed
+func ddlParseTrigger(schema, sql string) SQTrigger {
+	tokens := ddlTokenize(sql)
+
+	// CREATE [TEMP|TEMPORARY] TRIGGER [IF NOT EXISTS]
+	i := 0
+	if !isKeyword(tokens, i, "CREATE") {
+		return nil
+	} else {
+		i++
+	}
+	if isKeyword(tokens, i, "TEMP", "TEMPORARY") {
+		i++
+	}
+	if !isKeyword(tokens, i, "TRIGGER") {
+		return nil
+	} else {
+		i++
+	}
+	if isKeyword(tokens, i, "IF") && isKeyword(tokens, i+1, "NOT") && isKeyword(tokens, i+2, "EXISTS") {
+		i += 3
+	}
+
+	// [schema.]name
+	if i+1 < len(tokens) && tokens[i+1].kind == '.' {
+		i += 2
+	}
+	if i >= len(tokens) {
+		return nil
+	}
+	name := tokens[i].value
+	i++
+
+	// BEFORE, AFTER or INSTEAD OF, where the default is BEFORE
+	when := "BEFORE"
+	switch {
+	case isKeyword(tokens, i, "BEFORE"), isKeyword(tokens, i, "AFTER"):
+		when = strings.ToUpper(tokens[i].value)
+		i++
+	case isKeyword(tokens, i, "INSTEAD") && isKeyword(tokens, i+1, "OF"):
+		when = "INSTEAD OF"
+		i += 2
+	}
+
+	// DELETE, INSERT or UPDATE [OF column, ...]
+	var event string
+	var columns []string
+	switch {
+	case isKeyword(tokens, i, "DELETE"), isKeyword(tokens, i, "INSERT"):
+		event = strings.ToUpper(tokens[i].value)
+		i++
+	case isKeyword(tokens, i, "UPDATE"):
+		event = "UPDATE"
+		i++
+		if isKeyword(tokens, i, "OF") {
+			for i++; i < len(tokens) && !isKeyword(tokens, i, "ON"); i++ {
+				if tokens[i].kind != ',' {
+					columns = append(columns, tokens[i].value)
+				}
+			}
+		}
+	default:
+		return nil
+	}
+
+	// ON table [FOR EACH ROW]
+	if !isKeyword(tokens, i, "ON") || i+1 >= len(tokens) {
+		return nil
+	}
+	table := tokens[i+1].value
+	i += 2
+	if isKeyword(tokens, i, "FOR") {
+		if !isKeyword(tokens, i+1, "EACH") || !isKeyword(tokens, i+2, "ROW") {
+			return nil
+		}
+		i += 3
+	}
+
+	// [WHEN expr] BEGIN
+	var expr string
+	begin := i
+	for ; begin < len(tokens) && !isKeyword(tokens, begin, "BEGIN"); begin++ {
+		if tokens[begin].kind == '(' {
+			if j := ddlClose(tokens, begin); j > 0 {
+				begin = j
+			}
+		}
+	}
+	if begin >= len(tokens) {
+		return nil
+	} else if isKeyword(tokens, i, "WHEN") {
+		expr = strings.TrimSpace(sql[tokens[i].end:tokens[begin].pos])
+	}
+
+	// Statements are separated by semi-colons up to the final END
+	end := len(tokens) - 1
+	for end > begin && !isKeyword(tokens, end, "END") {
+		end--
+	}
+	statements := []SQStatement{}
+	for start, j := tokens[begin].end, begin+1; j <= end; j++ {
+		if tokens[j].kind == ';' || j == end {
+			if st := strings.TrimSpace(sql[start:tokens[j].pos]); st != "" {
+				statements = append(statements, Q(st))
+			}
+			start = tokens[j].end
+		}
+	}
+
+	// Construct the trigger
+	trigger := N(name).WithSchema(schema).CreateTrigger(table, statements...)
+	if trigger == nil {
+		return nil
+	}
+	if schema == tempSchema {
+		trigger = trigger.WithTemporary()
+	}
+	switch when {
+	case "BEFORE":
+		trigger = trigger.Before()
+	case "AFTER":
+		trigger = trigger.After()
+	default:
+		trigger = trigger.InsteadOf()
+	}
+	switch event {
+	case "DELETE":
+		trigger = trigger.Delete()
+	case "INSERT":
+		trigger = trigger.Insert()
+	default:
+		trigger = trigger.Update(columns...)
+	}
+	if expr != "" {
+		trigger = trigger.When(Q(expr))
+	}
+
+	// Return the trigger
+	return trigger
+}
+
+// ddlIndexWithSchema returns a CREATE INDEX statement with the index name
+// qualified by a schema, so that the index is created in that schema. The
+// statement is returned unchanged if it could not be parsed or the name is
+// already qualified.
+func ddlIndexWithSchema(schema, sql string) string {
+	tokens := ddlTokenize(sql)
+
+	// CREATE [UNIQUE] INDEX [IF NOT EXISTS]
+	i := 0
+	if !isKeyword(tokens, i, "CREATE") {
+		return sql
+	} else {
+		i++
+	}
+	if isKeyword(tokens, i, "UNIQUE") {
+		i++
+	}
+	if !isKeyword(tokens, i, "INDEX") {
+		return sql
+	} else {
+		i++
+	}
+	if isKeyword(tokens, i, "IF") && isKeyword(tokens, i+1, "NOT") && isKeyword(tokens, i+2, "EXISTS") {
+		i += 3
+	}
+
+	// [schema.]name
+	if i >= len(tokens) || i+1 < len(tokens) && tokens[i+1].kind == '.' {
+		return sql
+	}
+	return sql[:tokens[i].pos] + QuoteIdentifier(schema) + "." + sql[tokens[i].pos:]
+}
@@ -0,0 +1,191 @@
+package sqlite3
+
+import (
+	"fmt"
+	"sync"
+
+	// Packages
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Extension is a function, aggregate, collation or virtual table module
+// which is registered on each connection opened by a pool
+type Extension struct {
+	Kind          ExtensionKind
+	Name          string
+	Args          int  // Number of arguments for functions and aggregates, or -1 for any number
+	Deterministic bool // Functions and aggregates always return the same result for the same arguments
+	Func          ScalarFunc
+	Aggregate     AggregateFunc
+	Collation     CollationFunc
+	Module        sqlite3.Module
+}
+
+// ExtensionKind is the kind of an extension
+type ExtensionKind string
+
+// ScalarFunc returns the result of a function for the arguments
+type ScalarFunc func(args []interface{}) (interface{}, error)
+
+// AggregateFunc returns a new aggregator each time an aggregate function
+// is computed
+type AggregateFunc func() Aggregator
+
+// Aggregator computes the result of an aggregate function. Step is called
+// for each row, and Final is called to return the result.
+type Aggregator interface {
+	Step(args []interface{}) error
+	Final() (interface{}, error)
+}
+
+// CollationFunc compares two strings, and returns a negative number, zero
+// or a positive number
+type CollationFunc func(a, b string) int
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	ExtensionFunction  ExtensionKind = "function"
+	ExtensionAggregate ExtensionKind = "aggregate"
+	ExtensionCollation ExtensionKind = "collation"
+	ExtensionModule    ExtensionKind = "module"
+)
+
+var (
+	// Aggregators which are being computed, keyed by aggregate identifier
+	aggmu       sync.Mutex
+	aggregators = make(map[uintptr]Aggregator)
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// Function returns an extension for a scalar function
+func Function(name string, args int, deterministic bool, fn ScalarFunc) Extension {
+	return Extension{Kind: ExtensionFunction, Name: name, Args: args, Deterministic: deterministic, Func: fn}
+}
+
+// Aggregate returns an extension for an aggregate function
+func Aggregate(name string, args int, deterministic bool, fn AggregateFunc) Extension {
+	return Extension{Kind: ExtensionAggregate, Name: name, Args: args, Deterministic: deterministic, Aggregate: fn}
+}
+
+// Collation returns an extension for a collating sequence
+func Collation(name string, fn CollationFunc) Extension {
+	return Extension{Kind: ExtensionCollation, Name: name, Collation: fn}
+}
+
+// Module returns an extension for a read-only virtual table module
+func Module(name string, module sqlite3.Module) Extension {
+	return Extension{Kind: ExtensionModule, Name: name, Module: module}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e Extension) String() string {
+	str := "<extension"
+	str += fmt.Sprintf(" %v=%q", e.Kind, e.Name)
+	if e.Kind == ExtensionFunction || e.Kind == ExtensionAggregate {
+		str += fmt.Sprint(" args=", e.Args)
+		if e.Deterministic {
+			str += " deterministic"
+		}
+	}
+	return str + ">"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Register the extension on a connection
+func (e Extension) Register(conn *Conn) error {
+	if e.Name == "" {
+		return ErrBadParameter.With("Register: name")
+	}
+	switch e.Kind {
+	case ExtensionFunction:
+		if e.Func == nil {
+			return ErrBadParameter.Withf("Register %q: function", e.Name)
+		}
+		return conn.CreateScalarFunction(e.Name, e.Args, e.Deterministic, func(ctx *sqlite3.Context, args []*sqlite3.Value) {
+			v, err := e.Func(interfaceValues(args))
+			setResult(ctx, v, err)
+		})
+	case ExtensionAggregate:
+		if e.Aggregate == nil {
+			return ErrBadParameter.Withf("Register %q: aggregate", e.Name)
+		}
+		return conn.CreateAggregateFunction(e.Name, e.Args, e.Deterministic, func(ctx *sqlite3.Context, args []*sqlite3.Value) {
+			if err := aggregator(ctx, e.Aggregate).Step(interfaceValues(args)); err != nil {
+				ctx.Err(err.Error())
+			}
+		}, func(ctx *sqlite3.Context) {
+			a := aggregator(ctx, e.Aggregate)
+			aggmu.Lock()
+			delete(aggregators, ctx.AggregateId())
+			aggmu.Unlock()
+			v, err := a.Final()
+			setResult(ctx, v, err)
+		})
+	case ExtensionCollation:
+		if e.Collation == nil {
+			return ErrBadParameter.Withf("Register %q: collation", e.Name)
+		}
+		return conn.CreateCollation(e.Name, sqlite3.CollationFunc(e.Collation))
+	case ExtensionModule:
+		if e.Module == nil {
+			return ErrBadParameter.Withf("Register %q: module", e.Name)
+		}
+		return conn.CreateModule(e.Name, e.Module)
+	default:
+		return ErrBadParameter.Withf("Register %q: kind %q", e.Name, e.Kind)
+	}
+}
+
+// Extensions returns the extensions registered on each connection
+func (p *Pool) Extensions() []Extension {
+	return append([]Extension(nil), p.cfg.Extensions...)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// interfaceValues returns the go values for function arguments
+func interfaceValues(args []*sqlite3.Value) []interface{} {
+	result := make([]interface{}, len(args))
+	for i, arg := range args {
+		result[i] = arg.Interface()
+	}
+	return result
+}
+
+// setResult sets the result of a function, or an error
+func setResult(ctx *sqlite3.Context, v interface{}, err error) {
+	if err != nil {
+		ctx.Err(err.Error())
+	} else if err := ctx.ResultInterface(v); err != nil {
+		ctx.Err(fmt.Sprintf("Unsupported result type %T", v))
+	}
+}
+
+// aggregator returns the aggregator for an aggregate being computed, creating
+// a new aggregator on the first step
+func aggregator(ctx *sqlite3.Context, fn AggregateFunc) Aggregator {
+	id := ctx.AggregateId()
+	aggmu.Lock()
+	defer aggmu.Unlock()
+	if a, exists := aggregators[id]; exists {
+		return a
+	}
+	a := fn()
+	aggregators[id] = a
+	return a
+}
@@ -0,0 +1,193 @@
+package sqlite3_test
+
+import (
+	"context"
+	"testing"
+
+	// Module imports
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+type product struct {
+	v int64
+}
+
+func (p *product) Step(args []interface{}) error {
+	if v, ok := args[0].(int64); ok {
+		p.v *= v
+	}
+	return nil
+}
+
+func (p *product) Final() (interface{}, error) {
+	return p.v, nil
+}
+
+// digits is a virtual table module which returns the digits 0 to 9
+type digits struct{}
+
+type digitsCursor struct {
+	i int64
+}
+
+func (digits) Connect([]string) (sqlite3.VTable, string, error) {
+	return digits{}, "CREATE TABLE x(digit INTEGER)", nil
+}
+
+func (digits) BestIndex(info *sqlite3.IndexInfo) error {
+	info.SetEstimatedCost(10)
+	return nil
+}
+
+func (digits) Open() (sqlite3.VCursor, error) {
+	return &digitsCursor{}, nil
+}
+
+func (digits) Disconnect() error {
+	return nil
+}
+
+func (c *digitsCursor) Filter(int, string, []*sqlite3.Value) error {
+	c.i = 0
+	return nil
+}
+
+func (c *digitsCursor) Next() error {
+	c.i++
+	return nil
+}
+
+func (c *digitsCursor) EOF() bool {
+	return c.i >= 10
+}
+
+func (c *digitsCursor) Column(ctx *sqlite3.Context, _ int) error {
+	ctx.ResultInt64(c.i)
+	return nil
+}
+
+func (c *digitsCursor) Rowid() (int64, error) {
+	return c.i + 1, nil
+}
+
+func (c *digitsCursor) Close() error {
+	return nil
+}
+
+func Test_Extension_001(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+
+	cfg := NewConfig().WithExtensions(Stdlib()...).WithFunction("reverse", 1, true, func(args []interface{}) (interface{}, error) {
+		runes := []rune(args[0].(string))
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	}).WithAggregate("product", 1, true, func() Aggregator {
+		return &product{1}
+	}).WithCollation("length", func(a, b string) int {
+		return len(a) - len(b)
+	})
+	pool, err := OpenPool(cfg, errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	if n := len(pool.Extensions()); n != 6 {
+		t.Error("Unexpected number of extensions", n)
+	}
+
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+	if functions := conn.Functions("regexp", "reverse", "product"); len(functions) != 5 {
+		t.Error("Unexpected functions", functions)
+	}
+	if collations := conn.Collations("length"); len(collations) != 1 {
+		t.Error("Unexpected collations", collations)
+	}
+
+	tests := []struct {
+		sql    string
+		result interface{}
+	}{
+		{`SELECT 'hello' REGEXP '^h.*o$'`, int64(1)},
+		{`SELECT 'hello' REGEXP '^x'`, int64(0)},
+		{`SELECT NULL REGEXP '^x'`, nil},
+		{`SELECT regexp_substr('abc123def', '[0-9]+')`, "123"},
+		{`SELECT regexp_substr('abc', '[0-9]+')`, nil},
+		{`SELECT regexp_replace('a1b2', '[0-9]', '')`, "ab"},
+		{`SELECT reverse('hello')`, "olleh"},
+		{`SELECT product(value) FROM json_each('[1,2,3,4]')`, int64(24)},
+		{`SELECT product(value) FROM json_each('[]')`, int64(1)},
+		{`SELECT value FROM json_each('["ccc","a","bb"]') ORDER BY value COLLATE length LIMIT 1`, "a"},
+	}
+	for _, test := range tests {
+		if err := conn.Do(context.Background(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
+			rs, err := txn.Query(Q(test.sql))
+			if err != nil {
+				return err
+			}
+			if row := rs.Next(); row == nil {
+				t.Error("Unexpected nil row for", test.sql)
+			} else if row[0] != test.result {
+				t.Errorf("%s: got %v, expected %v", test.sql, row[0], test.result)
+			}
+			return nil
+		}); err != nil {
+			t.Error(test.sql, err)
+		}
+	}
+
+	// Invalid patterns return an error
+	if err := conn.Do(context.Background(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
+		_, err := txn.Query(Q(`SELECT 'a' REGEXP '('`))
+		return err
+	}); err == nil {
+		t.Error("Expected error, got", err)
+	}
+}
+
+func Test_Extension_002(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+
+	pool, err := OpenPool(NewConfig().WithModule("digits", digits{}), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+	if modules := conn.Modules("digits"); len(modules) != 1 {
+		t.Error("Unexpected modules", modules)
+	}
+
+	// Read the module as a table
+	if err := conn.Do(context.Background(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
+		rs, err := txn.Query(Q(`SELECT COUNT(*), SUM(digit) FROM digits WHERE digit > 4`))
+		if err != nil {
+			return err
+		}
+		if row := rs.Next(); row == nil {
+			t.Error("Unexpected nil row")
+		} else if row[0] != int64(5) || row[1] != int64(35) {
+			t.Error("Unexpected row", row)
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
@@ -12,7 +12,7 @@ import (
 
 func Test_ForeignKeys_001(t *testing.T) {
 	errs, cancel := handleErrors(t)
-	cfg := NewConfig().WithTrace(func(sql string, d time.Duration) {
+	cfg := NewConfig().WithTrace(func(_ *Conn, sql string, d time.Duration) {
 		if d > 0 {
 			t.Log(sql, "=>", d)
 		}
@@ -0,0 +1,240 @@
+package sqlite3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	// Packages
+	multierror "github.com/hashicorp/go-multierror"
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// LoadOptions control how rows are inserted by Load and LoadFunc
+type LoadOptions struct {
+	Schema         string // Schema of the table, or empty for the main schema
+	Commit         int    // Number of rows inserted in each transaction, or zero for a single transaction
+	Unsynchronized bool   // Set synchronous=OFF during the load
+	DeferIndexes   bool   // Drop the indexes which are not unique during the load, and create them afterwards
+}
+
+// RowIterator returns the next row to insert, or nil when there are no
+// more rows
+type RowIterator func() ([]interface{}, error)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Load inserts rows received from a channel into the columns of a table,
+// until the channel is closed or the context is cancelled. It returns the
+// number of rows committed.
+func (conn *Conn) Load(ctx context.Context, table string, columns []string, rows <-chan []interface{}, opts LoadOptions) (int, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return conn.LoadFunc(ctx, table, columns, func() ([]interface{}, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case row, ok := <-rows:
+			if !ok {
+				return nil, nil
+			}
+			return row, nil
+		}
+	}, opts)
+}
+
+// LoadFunc inserts rows returned by an iterator into the columns of a table,
+// until the iterator returns nil. Rows are inserted with statements of many
+// rows, as many as the limit on the number of parameters in a statement
+// allows. It returns the number of rows committed, which is less than the
+// number of rows when an error is returned. Transactions are not retried.
+func (conn *Conn) LoadFunc(ctx context.Context, table string, columns []string, next RowIterator, opts LoadOptions) (n int, result error) {
+	if table == "" || len(columns) == 0 || next == nil {
+		return 0, ErrBadParameter.With("Load")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	schema := opts.Schema
+	if schema == "" {
+		schema = DefaultSchema
+	}
+
+	// Loads are performed on the writer in WAL mode
+	target := conn
+	if conn.writer != nil {
+		target = conn.writer
+	}
+
+	// Determine the number of rows in each statement
+	batch := intMax(1, target.GetLimit(sqlite3.SQLITE_LIMIT_VARIABLE_NUMBER)/len(columns))
+	if opts.Commit > 0 {
+		batch = intMin(batch, opts.Commit)
+	}
+
+	// Set synchronous=OFF and drop indexes, restoring them when the load
+	// is complete
+	if opts.Unsynchronized {
+		if restore, err := target.setSynchronousOff(schema); err != nil {
+			return 0, err
+		} else {
+			defer func() {
+				if err := restore(); err != nil {
+					result = multierror.Append(result, err)
+				}
+			}()
+		}
+	}
+	if opts.DeferIndexes {
+		if indexes, err := target.dropIndexes(ctx, schema, table); err != nil {
+			return 0, err
+		} else {
+			defer func() {
+				if err := target.createIndexes(indexes); err != nil {
+					result = multierror.Append(result, err)
+				}
+			}()
+		}
+	}
+
+	// Insert rows in transactions, until there are no more rows
+	eof := false
+	for !eof {
+		count := 0
+		if err := target.do(ctx, 0, func(txn SQTransaction) error {
+			args := make([]interface{}, 0, batch*len(columns))
+			for !eof && (opts.Commit <= 0 || count < opts.Commit) {
+				row, err := next()
+				if err != nil {
+					return err
+				} else if row == nil {
+					eof = true
+				} else if len(row) != len(columns) {
+					return ErrBadParameter.Withf("Load: expected %d values, got %d", len(columns), len(row))
+				} else {
+					args = append(args, row...)
+					count++
+				}
+				if len(args) == batch*len(columns) || (eof || count == opts.Commit) && len(args) > 0 {
+					st := N(table).WithSchema(schema).Insert(columns...).WithRows(len(args) / len(columns))
+					if r, err := txn.Query(st, args...); err != nil {
+						return err
+					} else {
+						r.Close()
+					}
+					args = args[:0]
+				}
+			}
+			return nil
+		}); err != nil {
+			result = multierror.Append(result, err)
+			break
+		}
+		n += count
+	}
+
+	// Return the number of rows committed and any errors
+	return n, result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// setSynchronousOff sets synchronous=OFF for a schema, and returns a function
+// which restores the previous setting
+func (conn *Conn) setSynchronousOff(schema string) (func() error, error) {
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+
+	var mode string
+	if err := conn.Exec(Q("PRAGMA ", N(schema), ".synchronous"), func(row, _ []string) bool {
+		mode = row[0]
+		return false
+	}); err != nil {
+		return nil, err
+	} else if err := conn.Exec(Q("PRAGMA ", N(schema), ".synchronous=OFF"), nil); err != nil {
+		return nil, err
+	}
+	return func() error {
+		conn.Mutex.Lock()
+		defer conn.Mutex.Unlock()
+		return conn.Exec(Q("PRAGMA ", N(schema), ".synchronous=", mode), nil)
+	}, nil
+}
+
+// dropIndexes drops the indexes created for a table with CREATE INDEX which
+// are not unique, and returns the statements to create them again
+func (conn *Conn) dropIndexes(ctx context.Context, schema, table string) ([]string, error) {
+	var result []string
+	if err := conn.do(ctx, 0, func(txn SQTransaction) error {
+		// Determine the unique indexes, which are not dropped
+		unique := make(map[string]bool)
+		rs, err := txn.Query(Q("PRAGMA ", N(schema), ".index_list(", N(table), ")"))
+		if err != nil {
+			return err
+		}
+		for row := rs.Next(); row != nil; row = rs.Next() {
+			// columns are "seq" "name" "unique" "origin" "partial"
+			if row[2] != int64(0) {
+				unique[row[1].(string)] = true
+			}
+		}
+		rs.Close()
+
+		// Determine the indexes to drop
+		var names []string
+		rs, err = txn.Query(Q("SELECT name, sql FROM ", masterTable(schema), " WHERE type='index' AND tbl_name=? AND sql IS NOT NULL"), table)
+		if err != nil {
+			return err
+		}
+		for row := rs.Next(); row != nil; row = rs.Next() {
+			if name := row[0].(string); !unique[name] {
+				names = append(names, name)
+				result = append(result, ddlIndexWithSchema(schema, row[1].(string)))
+			}
+		}
+		rs.Close()
+		for _, name := range names {
+			if r, err := txn.Query(N(name).WithSchema(schema).DropIndex()); err != nil {
+				return err
+			} else {
+				r.Close()
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// createIndexes executes the statements returned by dropIndexes. Any error
+// includes the statements, so the indexes can be created later.
+func (conn *Conn) createIndexes(indexes []string) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	if err := conn.do(context.Background(), 0, func(txn SQTransaction) error {
+		for _, index := range indexes {
+			if r, err := txn.Query(Q(index)); err != nil {
+				return err
+			} else {
+				r.Close()
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("%w (indexes not created: %s)", err, strings.Join(indexes, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package sqlite3_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+func Test_Load_001(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+
+	// Count the insert statements executed
+	var inserts int
+	pool, err := OpenPool(NewConfig().WithSchema("main", filepath.Join(t.TempDir(), "main.sqlite")).WithWAL(true).WithTrace(func(_ *Conn, sql string, _ time.Duration) {
+		if strings.HasPrefix(sql, "INSERT") {
+			inserts++
+		}
+	}), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		if _, err := txn.Query(N("test").CreateTable(C("a"), C("b"))); err != nil {
+			return err
+		} else if _, err := txn.Query(Q("CREATE INDEX test_a ON test (a DESC) WHERE a > 0")); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Load rows from a channel
+	rows := make(chan []interface{})
+	go func() {
+		defer close(rows)
+		for i := 0; i < 10000; i++ {
+			rows <- []interface{}{i, "b"}
+		}
+	}()
+	if n, err := conn.(*Conn).Load(context.Background(), "test", []string{"a", "b"}, rows, LoadOptions{
+		Commit:         3000,
+		Unsynchronized: true,
+		DeferIndexes:   true,
+	}); err != nil {
+		t.Fatal(err)
+	} else if n != 10000 {
+		t.Error("Unexpected number of rows", n)
+	} else if inserts == 0 || inserts >= 100 {
+		t.Error("Unexpected number of insert statements", inserts)
+	}
+
+	// Check rows and index
+	if n := conn.Count("main", "test"); n != 10000 {
+		t.Error("Unexpected count", n)
+	}
+	if sql := conn.(*Conn).CreateStatement("main", "test_a"); !strings.Contains(sql, "WHERE a > 0") {
+		t.Error("Unexpected index", sql)
+	}
+
+	// Load rows with the wrong number of values
+	i := 0
+	if n, err := conn.(*Conn).LoadFunc(context.Background(), "test", []string{"a", "b"}, func() ([]interface{}, error) {
+		if i++; i > 10 {
+			return []interface{}{i}, nil
+		}
+		return []interface{}{i, "b"}, nil
+	}, LoadOptions{Commit: 5}); err == nil {
+		t.Error("Expected error")
+	} else if n != 10 {
+		t.Error("Unexpected number of rows", n)
+	}
+}
+
+func Test_Load_002(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+	pool, err := OpenPool(NewConfig(), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		if _, err := txn.Query(N("test").CreateTable(C("a"), C("b"))); err != nil {
+			return err
+		} else if _, err := txn.Query(Q("CREATE UNIQUE INDEX test_a ON test (a)")); err != nil {
+			return err
+		} else if _, err := txn.Query(Q("CREATE INDEX test_b ON test (b)")); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unique indexes are not dropped, so duplicate rows are not committed
+	i := 0
+	if n, err := conn.(*Conn).LoadFunc(context.Background(), "test", []string{"a", "b"}, func() ([]interface{}, error) {
+		if i++; i > 10 {
+			return nil, nil
+		}
+		return []interface{}{i % 5, "b"}, nil
+	}, LoadOptions{DeferIndexes: true}); err == nil {
+		t.Error("Expected error")
+	} else if n != 0 {
+		t.Error("Unexpected number of rows", n)
+	}
+	if n := conn.Count("main", "test"); n != 0 {
+		t.Error("Unexpected count", n)
+	}
+	if indexes := conn.IndexesForTable("main", "test"); len(indexes) != 2 {
+		t.Error("Unexpected indexes", indexes)
+	}
+}
@@ -0,0 +1,176 @@
+package sqlite3
+
+import (
+	"context"
+	"fmt"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+
+	// Namespace Imports
+	. "github.com/djthorpe/go-errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// ChangeEvent represents a row which has been inserted, updated or deleted
+// and committed
+type ChangeEvent struct {
+	Op     sqlite3.SQAction // One of SQLITE_INSERT, SQLITE_UPDATE or SQLITE_DELETE
+	Schema string           // Schema name
+	Table  string           // Table name
+	RowId  int64            // Rowid of the row, after any update
+}
+
+// ChangeFunc is a function that is called with changes once they have been
+// committed
+type ChangeFunc func(c *Conn, changes []ChangeEvent)
+
+// subscriber receives change events for a schema and optionally a set of
+// tables
+type subscriber struct {
+	schema string
+	tables []string
+	ch     chan ChangeEvent
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Number of change events buffered for each subscriber
+	defaultSubscriberCapacity = 100
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e ChangeEvent) String() string {
+	str := "<change"
+	str += fmt.Sprint(" op=", e.Op)
+	str += fmt.Sprintf(" schema=%q", e.Schema)
+	str += fmt.Sprintf(" table=%q", e.Table)
+	str += fmt.Sprint(" rowid=", e.RowId)
+	return str + ">"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - CONN
+
+// SetChangeHook sets a function to receive changes to rows. Changes are
+// buffered for each transaction and the function is only called once
+// the transaction has been committed. Changes which are rolled back are
+// dropped. Use nil to remove the hook.
+func (c *Conn) SetChangeHook(fn ChangeFunc) {
+	c.changefn = fn
+	c.changes, c.committed = nil, nil
+	if fn == nil {
+		c.ConnEx.SetUpdateHook(nil)
+	} else {
+		c.ConnEx.SetUpdateHook(func(op sqlite3.SQAction, schema, table string, rowid int64) {
+			c.changes = append(c.changes, ChangeEvent{op, schema, table, rowid})
+		})
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - POOL
+
+// Subscribe returns a channel which receives changes to rows in a schema
+// once they have been committed by any connection in the pool. If any
+// tables are provided, then only changes to those tables are received.
+// The channel is closed when the context is cancelled or the pool is closed.
+// Events are dropped and an error reported when the channel is full.
+func (p *Pool) Subscribe(ctx context.Context, schema string, tables ...string) (<-chan ChangeEvent, error) {
+	if schema == "" {
+		schema = DefaultSchema
+	}
+	if p.pathForSchema(schema) == "" {
+		return nil, ErrNotFound.Withf("Schema %q", schema)
+	}
+
+	// Add the subscriber, unless the pool is closed
+	s := &subscriber{schema, tables, make(chan ChangeEvent, defaultSubscriberCapacity)}
+	p.smu.Lock()
+	select {
+	case <-p.done:
+		p.smu.Unlock()
+		return nil, ErrOutOfOrder.With("Subscribe: pool is closed")
+	default:
+	}
+	if p.subs == nil {
+		p.subs = make(map[*subscriber]bool)
+	}
+	p.subs[s] = true
+	p.smu.Unlock()
+
+	// Remove the subscriber when the context is done, or return when the
+	// pool is closed, which closes the channel
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.unsubscribe(s)
+		case <-p.done:
+		}
+	}()
+
+	// Return success
+	return s.ch, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// notify calls the change hook with committed changes. If err is not nil,
+// the commit failed and the changes are dropped
+func (c *Conn) notify(err error) {
+	if len(c.committed) == 0 {
+		return
+	}
+	changes := c.committed
+	c.committed = nil
+	if err == nil && c.changefn != nil {
+		c.changefn(c, changes)
+	}
+}
+
+// publish sends changes to any matching subscribers
+func (p *Pool) publish(_ *Conn, changes []ChangeEvent) {
+	p.smu.RLock()
+	defer p.smu.RUnlock()
+	for s := range p.subs {
+		for _, change := range changes {
+			if !s.matches(change) {
+				continue
+			}
+			select {
+			case s.ch <- change:
+				continue
+			default:
+				p.err(ErrChannelBlocked.Withf("Subscribe: dropped %v", change))
+			}
+		}
+	}
+}
+
+// unsubscribe removes a subscriber and closes the channel
+func (p *Pool) unsubscribe(s *subscriber) {
+	p.smu.Lock()
+	defer p.smu.Unlock()
+	if _, exists := p.subs[s]; exists {
+		delete(p.subs, s)
+		close(s.ch)
+	}
+}
+
+// matches returns true if a change should be sent to the subscriber
+func (s *subscriber) matches(change ChangeEvent) bool {
+	if change.Schema != s.schema {
+		return false
+	}
+	if len(s.tables) == 0 {
+		return true
+	}
+	return inList(s.tables, change.Table, false)
+}
@@ -0,0 +1,279 @@
+package sqlite3_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+func Test_Notify_001(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+	pool, err := NewPool("", errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Subscribe to changes in the test table
+	ctx, unsubscribe := context.WithCancel(context.Background())
+	ch, err := pool.Subscribe(ctx, "", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+
+	// Create tables, insert rows which are committed and rolled back
+	if err := conn.Exec(N("test").CreateTable(C("a").WithType("INTEGER").WithPrimary()), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Exec(N("other").CreateTable(C("a")), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		if _, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)")); err != nil {
+			return err
+		}
+		if _, err := txn.Query(Q("INSERT INTO other (a) VALUES (1)")); err != nil {
+			return err
+		}
+		_, err := txn.Query(Q("UPDATE test SET a=2 WHERE a=1"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		if _, err := txn.Query(Q("INSERT INTO test (a) VALUES (3)")); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	}); err == nil {
+		t.Fatal("Expected error")
+	}
+	if err := conn.Exec(Q("DELETE FROM test WHERE a=2"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check events, the channel is closed on unsubscribe
+	unsubscribe()
+	expected := []ChangeEvent{
+		{sqlite3.SQLITE_INSERT, "main", "test", 1},
+		{sqlite3.SQLITE_UPDATE, "main", "test", 2},
+		{sqlite3.SQLITE_DELETE, "main", "test", 2},
+	}
+	var i int
+	for evt := range ch {
+		t.Log(evt)
+		if i >= len(expected) {
+			t.Error("Unexpected event", evt)
+		} else if evt != expected[i] {
+			t.Error("Expected", expected[i], "got", evt)
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Error("Unexpected number of events", i)
+	}
+}
+
+func Test_Notify_002(t *testing.T) {
+	pool, err := NewPool("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	if _, err := pool.Subscribe(context.Background(), "other"); err == nil {
+		t.Error("Expected error for missing schema")
+	}
+}
+
+func Test_Notify_005(t *testing.T) {
+	pool, err := NewPool("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Subscribers which are never cancelled are released when the pool is closed
+	n := runtime.NumGoroutine()
+	var chs []<-chan ChangeEvent
+	for i := 0; i < 10; i++ {
+		ch, err := pool.Subscribe(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		chs = append(chs, ch)
+	}
+	if err := pool.Close(); err != nil {
+		t.Error(err)
+	}
+	for _, ch := range chs {
+		if _, ok := <-ch; ok {
+			t.Error("Expected channel to be closed")
+		}
+	}
+	for i := 0; i < 100 && runtime.NumGoroutine() > n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m := runtime.NumGoroutine(); m > n {
+		t.Error("Unexpected goroutines", m-n)
+	}
+
+	// Subscribing to a closed pool returns an error
+	if _, err := pool.Subscribe(context.Background(), ""); err == nil {
+		t.Error("Expected error for closed pool")
+	}
+}
+
+func Test_Notify_003(t *testing.T) {
+	conn, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Exec(N("test").CreateTable(C("a").WithType("INTEGER").WithPrimary()), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Commit transaction, only commit functions should be called
+	var commit, rollback int
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		txn.OnCommit(func() { commit++ })
+		txn.OnRollback(func() { rollback++ })
+		if commit != 0 {
+			t.Error("Unexpected commit before end of transaction")
+		}
+		_, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if commit != 1 || rollback != 0 {
+		t.Error("Unexpected commit=", commit, " rollback=", rollback)
+	}
+
+	// Rollback transaction on a constraint failure, only rollback functions should be called
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		txn.OnCommit(func() { commit++ })
+		txn.OnRollback(func() { rollback++ })
+		_, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)"))
+		return err
+	}); err == nil {
+		t.Fatal("Expected error")
+	}
+	if commit != 1 || rollback != 1 {
+		t.Error("Unexpected commit=", commit, " rollback=", rollback)
+	}
+
+	// Outside of a transaction, commit functions are called immediately
+	conn.OnCommit(func() { commit++ })
+	conn.OnRollback(func() { rollback++ })
+	if commit != 2 || rollback != 1 {
+		t.Error("Unexpected commit=", commit, " rollback=", rollback)
+	}
+
+	// Commit functions can use the connection
+	var n int64
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		txn.OnCommit(func() {
+			n = conn.Count("main", "test")
+			if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+				_, err := txn.Query(Q("INSERT INTO test (a) VALUES (3)"))
+				return err
+			}); err != nil {
+				t.Error(err)
+			}
+		})
+		_, err := txn.Query(Q("INSERT INTO test (a) VALUES (2)"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Error("Unexpected count in commit function", n)
+	} else if n := conn.Count("main", "test"); n != 3 {
+		t.Error("Unexpected count", n)
+	}
+}
+
+func Test_Notify_004(t *testing.T) {
+	conn, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.Exec(N("test").CreateTable(C("a").WithType("INTEGER").WithPrimary()), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nested transactions, the inner one is rolled back
+	var commit, rollback int
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		if _, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)")); err != nil {
+			return err
+		}
+		if err := txn.Do(context.Background(), 0, func(txn SQTransaction) error {
+			txn.OnCommit(func() { commit++ })
+			_, err := txn.Query(Q("INSERT INTO test (a) VALUES (2)"))
+			return err
+		}); err != nil {
+			return err
+		}
+		if err := txn.Do(context.Background(), 0, func(txn SQTransaction) error {
+			txn.OnCommit(func() { commit++ })
+			txn.OnRollback(func() {
+				// Called once the connection is unlocked, so it can be used
+				rollback++
+				if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+					_, err := txn.Query(Q("INSERT INTO test (a) VALUES (4)"))
+					return err
+				}); err != nil {
+					t.Error(err)
+				}
+			})
+			if _, err := txn.Query(Q("INSERT INTO test (a) VALUES (3)")); err != nil {
+				return err
+			}
+			return errors.New("rollback")
+		}); err == nil {
+			t.Error("Expected error")
+		}
+		if rollback != 0 {
+			t.Error("Expected rollback function to be called after the transaction, rollback=", rollback)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if commit != 1 || rollback != 1 {
+		t.Error("Unexpected commit=", commit, " rollback=", rollback)
+	}
+	if n := conn.Count("main", "test"); n != 3 {
+		t.Error("Expected three rows, got", n)
+	}
+
+	// A cancelled context returns an error without creating a savepoint
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		return txn.Do(ctx, 0, nil)
+	}); err == nil {
+		t.Error("Expected error")
+	}
+}
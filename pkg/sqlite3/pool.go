@@ -1,13 +1,14 @@
 package sqlite3
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unsafe"
 
 	// Modules
 	multierror "github.com/hashicorp/go-multierror"
@@ -16,6 +17,7 @@ import (
 	// Namespace Imports
 	. "github.com/djthorpe/go-errors"
 	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -23,35 +25,80 @@ import (
 
 // PoolConfig is the starting configuration for a pool
 type PoolConfig struct {
-	Max     int32             `yaml:"max"`       // The maximum number of connections in the pool
-	Schemas map[string]string `yaml:"databases"` // Schema names mapped onto path for database file
-	Create  bool              `yaml:"create"`    // When false, do not allow creation of new file-based databases
-	Auth    SQAuth            // Authentication and Authorization interface
-	Trace   TraceFunc         // Trace function
-	Flags   SQFlag            // Flags for opening connections
+	Max      int32             `yaml:"max"`       // The maximum number of connections in the pool
+	Schemas  map[string]string `yaml:"databases"` // Schema names mapped onto path for database file
+	Create   bool              `yaml:"create"`    // When false, do not allow creation of new file-based databases
+	Auth     SQAuth            // Authentication and Authorization interface
+	Trace    TraceFunc         // Trace function
+	Tracer   Tracer            // Tracer for statement spans
+	Profile  bool              `yaml:"profile"` // Profile statement execution times
+	Flags    SQFlag            // Flags for opening connections
+	Timeout  time.Duration     `yaml:"timeout"`  // Maximum time to wait for a connection
+	WAL      bool              `yaml:"wal"`      // Use WAL mode with a single writer connection
+	ReadOnly bool              `yaml:"readonly"` // Open all connections read-only
+
+	MaxIdle     int32         `yaml:"max_idle"`     // Maximum number of idle connections, or zero for no limit
+	IdleTimeout time.Duration `yaml:"idle_timeout"` // Close connections which are idle for longer than this duration
+	MaxLifetime time.Duration `yaml:"max_lifetime"` // Close connections which have been open for longer than this duration
+	Ping        bool          `yaml:"ping"`         // Check connections are healthy when checked out
+	CacheSize   uint32        `yaml:"cache_size"`   // Maximum number of cached prepared statements for each connection
+	OnConnect   ConnectFunc   // Called for each new connection
+	Extensions  []Extension   // Functions, aggregates, collations and modules registered on each new connection
+
+	BusyTimeout time.Duration `yaml:"busy_timeout"` // Time to wait for a lock before a statement fails with SQLITE_BUSY
+	Retry       RetryPolicy   `yaml:"retry"`        // Policy for retrying transactions when the database is busy or locked
 }
 
 // Pool is a connection pool object
 type Pool struct {
 	cfg   PoolConfig   // The configuration for the pool
-	pool  sync.Pool    // The pool of connections
 	errs  chan<- error // Errors are sent to this channel
-	n     int32        // The number of connections in the pool
+	n     int32        // The number of connections checked out
 	drain int32        // Pool is draining (boolean)
+
+	mu      sync.Mutex     // Guards the free list and wait queue
+	open    int32          // The number of open connections
+	free    []*Conn        // Idle connections
+	waiters []chan *Conn   // Queue of callers waiting for a connection
+	writer  *Conn          // Writer connection in WAL mode
+	stop    chan struct{}  // Stops closing expired connections
+	conns   map[*Conn]bool // All open connections
+
+	smx     sync.RWMutex // Guards the schemas in the configuration
+	schemas int64        // Incremented when a schema is attached or detached
+
+	// Statistics, guarded by the mutex
+	checkouts, waits int64
+	wait             time.Duration
+	closed           map[string]int64
+
+	smu  sync.RWMutex         // Guards subscribers
+	subs map[*subscriber]bool // Subscribers to change events
+	done chan struct{}        // Closed when the pool is closed
+
+	profile *profilearray // Statement profiles, or nil if profiling is disabled
 }
 
-// TraceFunc is a function that is called when a statement is executed or prepared
+// TraceFunc is a function that is called when a statement is executed or prepared.
+// The trace function for a pool is only called when each statement has completed,
+// with the SQL and the time taken
 type TraceFunc func(c *Conn, q string, delta time.Duration)
 
+// ConnectFunc is a function that is called when a new connection is opened,
+// which can set pragmas, register functions and so forth. If an error is
+// returned, the connection is closed.
+type ConnectFunc func(c *Conn) error
+
 ////////////////////////////////////////////////////////////////////////////////
 // GLOBALS
 
 var (
 	reSchemaName      = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9_-]+$")
 	defaultPoolConfig = PoolConfig{
-		Max:    5,
-		Create: true,
-		Flags:  SQFlag(sqlite3.SQLITE_OPEN_CREATE) | SQFlag(sqlite3.SQLITE_OPEN_SHAREDCACHE) | SQLITE_OPEN_CACHE,
+		Max:     5,
+		Create:  true,
+		Flags:   SQFlag(sqlite3.SQLITE_OPEN_CREATE) | SQFlag(sqlite3.SQLITE_OPEN_SHAREDCACHE) | SQLITE_OPEN_CACHE,
+		Timeout: 5 * time.Second,
 	}
 )
 
@@ -77,6 +124,21 @@ func (cfg PoolConfig) WithTrace(fn TraceFunc) PoolConfig {
 	return cfg
 }
 
+// Enable spans of statement execution
+func (cfg PoolConfig) WithTracer(tracer Tracer) PoolConfig {
+	cfg.Tracer = tracer
+	return cfg
+}
+
+// Enable or disable profiling of statements. Statements which differ only
+// by literal values are profiled together, and the slowest and most
+// frequently executed statements are returned by SlowQueries and
+// FrequentQueries.
+func (cfg PoolConfig) WithProfile(profile bool) PoolConfig {
+	cfg.Profile = profile
+	return cfg
+}
+
 // Enable or disable creation of database files
 func (cfg PoolConfig) WithCreate(create bool) PoolConfig {
 	cfg.Create = create
@@ -91,12 +153,132 @@ func (cfg PoolConfig) WithMaxConnections(n int) PoolConfig {
 	return cfg
 }
 
+// Set maximum time to wait for a connection when all connections are
+// checked out. Setting a value of zero will use the default timeout.
+func (cfg PoolConfig) WithTimeout(timeout time.Duration) PoolConfig {
+	if timeout >= 0 {
+		cfg.Timeout = timeout
+	}
+	return cfg
+}
+
+// Enable or disable WAL mode. In WAL mode, databases are put into
+// write-ahead logging mode, and the pool keeps a single writer connection
+// in addition to the read-only connections which are checked out. Any
+// transaction which is not read-only is performed on the writer.
+func (cfg PoolConfig) WithWAL(wal bool) PoolConfig {
+	cfg.WAL = wal
+	return cfg
+}
+
+// Enable or disable read-only mode. In read-only mode, all connections are
+// opened read-only, so that databases cannot be created or modified. Memory
+// databases are not supported in read-only mode.
+func (cfg PoolConfig) WithReadOnly(readonly bool) PoolConfig {
+	cfg.ReadOnly = readonly
+	return cfg
+}
+
+// Set maximum number of idle connections kept in the pool. Setting a
+// value of zero keeps up to the maximum number of connections.
+func (cfg PoolConfig) WithMaxIdle(n int) PoolConfig {
+	if n >= 0 {
+		cfg.MaxIdle = int32(n)
+	}
+	return cfg
+}
+
+// Close connections which have been idle for longer than the timeout.
+// Setting a value of zero never closes idle connections.
+func (cfg PoolConfig) WithIdleTimeout(timeout time.Duration) PoolConfig {
+	if timeout >= 0 {
+		cfg.IdleTimeout = timeout
+	}
+	return cfg
+}
+
+// Close connections which have been open for longer than the lifetime.
+// Setting a value of zero never closes connections due to age.
+func (cfg PoolConfig) WithMaxLifetime(lifetime time.Duration) PoolConfig {
+	if lifetime >= 0 {
+		cfg.MaxLifetime = lifetime
+	}
+	return cfg
+}
+
+// Enable or disable checking connections are healthy when checked out
+func (cfg PoolConfig) WithPing(ping bool) PoolConfig {
+	cfg.Ping = ping
+	return cfg
+}
+
+// Set a function which is called for each new connection
+func (cfg PoolConfig) WithOnConnect(fn ConnectFunc) PoolConfig {
+	cfg.OnConnect = fn
+	return cfg
+}
+
+// Set the maximum number of prepared statements cached for each connection,
+// evicting the least recently used statements. Setting a value of zero
+// will use the default size.
+func (cfg PoolConfig) WithCacheSize(n int) PoolConfig {
+	if n >= 0 {
+		cfg.CacheSize = uint32(n)
+	}
+	return cfg
+}
+
+// Set the time a statement waits for a lock to be released before failing
+// with SQLITE_BUSY. Setting a value of zero will use the default timeout
+// of five seconds.
+func (cfg PoolConfig) WithBusyTimeout(timeout time.Duration) PoolConfig {
+	if timeout >= 0 {
+		cfg.BusyTimeout = timeout
+	}
+	return cfg
+}
+
+// Set the policy for retrying transactions which fail because the database
+// is busy or locked
+func (cfg PoolConfig) WithRetry(r RetryPolicy) PoolConfig {
+	cfg.Retry = r
+	return cfg
+}
+
 // Add schema to the pool
 func (cfg PoolConfig) WithSchema(name, path string) PoolConfig {
 	cfg.Schemas[name] = path
 	return cfg
 }
 
+// Register extensions on each new connection
+func (cfg PoolConfig) WithExtensions(extensions ...Extension) PoolConfig {
+	cfg.Extensions = append(append([]Extension(nil), cfg.Extensions...), extensions...)
+	return cfg
+}
+
+// Register a scalar function on each new connection. Set args to -1 for
+// a function which accepts any number of arguments
+func (cfg PoolConfig) WithFunction(name string, args int, deterministic bool, fn ScalarFunc) PoolConfig {
+	return cfg.WithExtensions(Function(name, args, deterministic, fn))
+}
+
+// Register an aggregate function on each new connection. Set args to -1 for
+// a function which accepts any number of arguments
+func (cfg PoolConfig) WithAggregate(name string, args int, deterministic bool, fn AggregateFunc) PoolConfig {
+	return cfg.WithExtensions(Aggregate(name, args, deterministic, fn))
+}
+
+// Register a collating sequence on each new connection
+func (cfg PoolConfig) WithCollation(name string, fn CollationFunc) PoolConfig {
+	return cfg.WithExtensions(Collation(name, fn))
+}
+
+// Register a virtual table module on each new connection
+func (cfg PoolConfig) WithModule(name string, module sqlite3.Module) PoolConfig {
+	return cfg.WithExtensions(Module(name, module))
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
@@ -123,11 +305,29 @@ func OpenPool(config PoolConfig, errs chan<- error) (*Pool, error) {
 		config.Max = maxInt32(config.Max, 1)
 	}
 
+	// Set default timeout if not set
+	if config.Timeout == 0 {
+		config.Timeout = defaultPoolConfig.Timeout
+	}
+
 	// Set default flags if not set
 	if config.Flags == 0 {
 		config.Flags = defaultPoolConfig.Flags
 	}
 
+	// In read-only mode, memory databases are not supported and connections
+	// cannot create databases
+	if config.ReadOnly {
+		for schema := range config.Schemas {
+			if config.Schemas[schema] == defaultMemory {
+				return nil, ErrBadParameter.Withf("Schema %q: read-only mode is not supported for memory databases", schema)
+			}
+		}
+		config.Create = false
+		config.Flags &^= SQFlag(sqlite3.SQLITE_OPEN_READWRITE)
+		config.Flags |= SQFlag(sqlite3.SQLITE_OPEN_READONLY)
+	}
+
 	// Update create flag
 	if config.Create {
 		config.Flags |= SQFlag(sqlite3.SQLITE_OPEN_CREATE)
@@ -135,45 +335,112 @@ func OpenPool(config PoolConfig, errs chan<- error) (*Pool, error) {
 		config.Flags &^= SQFlag(sqlite3.SQLITE_OPEN_CREATE)
 	}
 
-	// Set up pool
+	// In WAL mode, memory databases are not supported and connections
+	// do not share a cache
+	if config.WAL {
+		for schema := range config.Schemas {
+			if config.Schemas[schema] == defaultMemory {
+				return nil, ErrBadParameter.Withf("Schema %q: WAL mode is not supported for memory databases", schema)
+			}
+		}
+		config.Flags &^= SQFlag(sqlite3.SQLITE_OPEN_SHAREDCACHE)
+	}
+
+	// Set up pool, copying the schemas which can be changed later
 	p.cfg = config
+	p.cfg.Schemas = make(map[string]string, len(config.Schemas))
+	for schema, path := range config.Schemas {
+		p.cfg.Schemas[strings.TrimSpace(schema)] = path
+	}
+	if config.Profile {
+		p.profile = NewProfileArray(defaultProfileSize, defaultSampleSize, defaultAge)
+	}
 	p.errs = errs
-	p.pool = sync.Pool{New: func() interface{} {
-		if conn, errs := p.new(); errs != nil {
-			p.err(errs)
-			return nil
+	p.conns = make(map[*Conn]bool)
+	p.closed = make(map[string]int64)
+	p.done = make(chan struct{})
+
+	// Create the writer connection in WAL mode, unless all connections
+	// are read-only
+	if config.WAL && !config.ReadOnly {
+		if conn, err := p.newWriter(); err != nil {
+			return nil, err
 		} else {
-			return conn
+			p.writer = conn
 		}
-	}}
+	}
 
 	// Create a single connection and put in the pool
 	if conn, errs := p.new(); errs != nil {
+		if p.writer != nil {
+			p.writer.Close()
+		}
 		return nil, errs
 	} else {
-		p.Put(conn)
-		p.n = 0
+		p.open = 1
+		conn.idle = time.Now()
+		p.free = append(p.free, conn)
+		p.conns[conn] = true
+	}
+
+	// Close expired connections in the background
+	if interval := p.reapInterval(); interval > 0 {
+		p.stop = make(chan struct{})
+		go p.reap(interval, p.stop)
 	}
 
 	// Return success
 	return p, nil
 }
 
-// Close waits for all connections to be released and then
-// releases resources
+// Close releases idle connections and any connections which are
+// subsequently returned to the pool
 func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// Drain the pool
 	atomic.StoreInt32(&p.drain, 1)
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
 
+	// Close idle connections
 	var result error
-	for {
-		conn := p.pool.Get()
-		if conn == nil {
-			break
-		} else if err := conn.(*Conn).Close(); err != nil {
+	for _, conn := range p.free {
+		if err := conn.Close(); err != nil {
 			result = multierror.Append(result, err)
 		}
+		p.open--
+		p.closed[ClosedPool]++
+		delete(p.conns, conn)
+	}
+	p.free = nil
+
+	// Close the writer once all connections are released
+	if err := p.closeWriter(); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	// Release any callers waiting for a connection
+	for _, w := range p.waiters {
+		close(w)
 	}
+	p.waiters = nil
+
+	// Close any subscribers, and release their goroutines
+	p.smu.Lock()
+	for s := range p.subs {
+		delete(p.subs, s)
+		close(s.ch)
+	}
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+	}
+	p.smu.Unlock()
 
 	// Return any errors
 	return result
@@ -188,8 +455,9 @@ func (p *Pool) String() string {
 	str += fmt.Sprint(" flags=", sqlite3.OpenFlags(p.cfg.Flags))
 	str += fmt.Sprint(" cur=", p.Cur())
 	str += fmt.Sprint(" max=", p.Max())
-	for schema := range p.cfg.Schemas {
-		str += fmt.Sprintf(" <schema %s=%q>", strings.TrimSpace(schema), p.pathForSchema(schema))
+	schemas, _ := p.schemasForConn()
+	for schema, path := range schemas {
+		str += fmt.Sprintf(" <schema %s=%q>", schema, path)
 	}
 	return str + ">"
 }
@@ -197,24 +465,246 @@ func (p *Pool) String() string {
 ////////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
+// Get a connection from the pool, waiting up to the configured timeout for
+// a connection to be released. Returns nil and reports an error if no
+// connection could be obtained.
 func (p *Pool) Get() SQConnection {
-	if conn, ok := p.pool.Get().(SQConnection); ok {
-		// Increment counter of open connections
-		atomic.AddInt32(&p.n, 1)
-		return conn
-	} else {
+	if conn, err := p.GetContext(context.Background()); err != nil {
+		p.err(err)
 		return nil
+	} else {
+		return conn
 	}
 }
 
-func (p *Pool) Put(conn SQConnection) {
-	if conn != nil {
-		// Decrement counter of open connections
+// GetContext returns a connection from the pool. If all connections are
+// checked out, the caller waits in a queue until a connection is released,
+// the context is cancelled or the configured timeout is reached. Callers
+// are served in the order they started waiting.
+func (p *Pool) GetContext(ctx context.Context) (SQConnection, error) {
+	if p.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+		defer cancel()
+	}
+
+	// Check out a connection, attaching or detaching any schemas which
+	// have changed and discarding any connections which are not healthy
+	for {
+		conn, err := p.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var reason string
+		if err := p.syncSchemas(conn); err != nil {
+			p.err(err)
+			reason = ClosedAttach
+		} else if err := p.ping(conn); err != nil {
+			p.err(err)
+			reason = ClosedPing
+		} else {
+			return conn, nil
+		}
+		p.mu.Lock()
 		atomic.AddInt32(&p.n, -1)
-		p.pool.Put(conn)
+		p.discard(conn, reason)
+		p.mu.Unlock()
+	}
+}
+
+// Put returns a connection to the pool, handing it to the caller which
+// has been waiting longest
+func (p *Pool) Put(conn SQConnection) {
+	if conn, ok := conn.(*Conn); ok && conn != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.release(conn)
+	}
+}
+
+// get returns an idle connection, a new connection or waits for a connection
+// to be released
+func (p *Pool) get(ctx context.Context) (*Conn, error) {
+	p.mu.Lock()
+	if atomic.LoadInt32(&p.drain) != 0 {
+		p.mu.Unlock()
+		return nil, ErrOutOfOrder.With("Pool is closed")
+	}
+
+	// Return an idle connection or open a new one, unless other callers
+	// are already waiting
+	if len(p.waiters) == 0 {
+		for n := len(p.free); n > 0; n = len(p.free) {
+			conn := p.free[n-1]
+			p.free = p.free[:n-1]
+			if reason := p.expired(conn, time.Now()); reason != "" {
+				p.discard(conn, reason)
+				continue
+			}
+			atomic.AddInt32(&p.n, 1)
+			p.checkouts++
+			p.mu.Unlock()
+			return conn, nil
+		}
+		if p.open < p.cfg.Max {
+			p.open++
+			atomic.AddInt32(&p.n, 1)
+			p.checkouts++
+			p.mu.Unlock()
+			return p.create()
+		}
+	}
+
+	// Wait in the queue. A nil connection is received when a new
+	// connection can be opened, or the channel is closed when the pool is closed
+	w := make(chan *Conn, 1)
+	p.waiters = append(p.waiters, w)
+	p.waits++
+	p.mu.Unlock()
+	start := time.Now()
+	defer p.waited(start)
+	select {
+	case conn, ok := <-w:
+		if !ok {
+			return nil, ErrOutOfOrder.With("Pool is closed")
+		} else if conn == nil {
+			return p.create()
+		} else {
+			return conn, nil
+		}
+	case <-ctx.Done():
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if !p.dequeue(w) {
+			// A connection or slot was handed over while the context was
+			// cancelled, so release it again
+			if conn, ok := <-w; ok && conn != nil {
+				p.release(conn)
+			} else if ok {
+				p.open--
+				atomic.AddInt32(&p.n, -1)
+				p.next()
+			}
+		}
+		return nil, ctx.Err()
 	}
 }
 
+// Attach a database to the pool with a schema name. The schema is attached
+// to each connection when it is next checked out, so connections which are
+// already checked out are not affected. In WAL mode, the schema is attached
+// to the writer once any transaction in progress has completed. If path is
+// empty, a memory database is attached.
+func (p *Pool) Attach(schema, path string) error {
+	if !reSchemaName.MatchString(schema) || schema == DefaultSchema || schema == tempSchema {
+		return ErrBadParameter.Withf("Attach: %q", schema)
+	}
+	if path == "" {
+		path = defaultMemory
+	}
+	if path == defaultMemory && (p.cfg.WAL || p.cfg.ReadOnly) {
+		return ErrBadParameter.Withf("Attach %q: memory databases are not supported in WAL or read-only mode", schema)
+	} else if path != defaultMemory && !p.cfg.Create {
+		if _, err := os.Stat(path); err != nil {
+			return ErrNotFound.Withf("Attach %q: %v", schema, err)
+		}
+	}
+
+	p.smx.Lock()
+	defer p.smx.Unlock()
+	if _, exists := p.cfg.Schemas[schema]; exists {
+		return ErrDuplicateEntry.Withf("Attach: %q", schema)
+	}
+
+	// Attach to the writer, waiting for any transaction to complete
+	if writer := p.currentWriter(); writer != nil {
+		writer.Mutex.Lock()
+		defer writer.Mutex.Unlock()
+		writer.noauth = true
+		defer func() { writer.noauth = false }()
+		if err := writer.Attach(schema, path); err != nil {
+			return err
+		} else if err := setJournalModeWAL(writer, schema); err != nil {
+			writer.Detach(schema)
+			return err
+		}
+	}
+
+	// Update the configuration
+	p.cfg.Schemas[schema] = path
+	p.schemas++
+
+	// Return success
+	return nil
+}
+
+// Detach a database from the pool. The schema is detached from each
+// connection when it is next checked out, so connections which are already
+// checked out are not affected. In WAL mode, the schema is detached from the
+// writer once any transaction in progress has completed.
+func (p *Pool) Detach(schema string) error {
+	if schema == DefaultSchema || schema == tempSchema {
+		return ErrBadParameter.Withf("Detach: %q", schema)
+	}
+
+	p.smx.Lock()
+	defer p.smx.Unlock()
+	if _, exists := p.cfg.Schemas[schema]; !exists {
+		return ErrNotFound.Withf("Detach: %q", schema)
+	}
+
+	// Detach from the writer, waiting for any transaction to complete
+	if writer := p.currentWriter(); writer != nil {
+		writer.Mutex.Lock()
+		defer writer.Mutex.Unlock()
+		writer.noauth = true
+		defer func() { writer.noauth = false }()
+		if err := writer.Detach(schema); err != nil {
+			return err
+		}
+	}
+
+	// Update the configuration
+	delete(p.cfg.Schemas, schema)
+	p.schemas++
+
+	// Return success
+	return nil
+}
+
+// Writer calls a function with the writer connection in WAL mode, once any
+// transaction in progress has completed. No transactions can write until the
+// function returns. Returns an error if the pool is not in WAL mode.
+func (p *Pool) Writer(fn func(*Conn) error) error {
+	writer := p.currentWriter()
+	if writer == nil {
+		return ErrNotImplemented.With("Writer: pool is not in WAL mode")
+	}
+	writer.Mutex.Lock()
+	defer writer.Mutex.Unlock()
+	writer.noauth = true
+	defer func() { writer.noauth = false }()
+	return fn(writer)
+}
+
+// SlowQueries returns up to n statement profiles with the slowest mean
+// execution time first, or nil if profiling is not enabled
+func (p *Pool) SlowQueries(n int) []*QueryProfile {
+	if p.profile == nil {
+		return nil
+	}
+	return p.profile.SlowQueries(n)
+}
+
+// FrequentQueries returns up to n statement profiles with the most frequently
+// executed statements first, or nil if profiling is not enabled
+func (p *Pool) FrequentQueries(n int) []*QueryProfile {
+	if p.profile == nil {
+		return nil
+	}
+	return p.profile.FrequentQueries(n)
+}
+
 // Return number of "checked out" (used) connections
 func (p *Pool) Cur() int {
 	return int(atomic.LoadInt32(&p.n))
@@ -222,40 +712,91 @@ func (p *Pool) Cur() int {
 
 // Return maximum allowed connections
 func (p *Pool) Max() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return int(p.cfg.Max)
 }
 
 // Set maximum number of "checked out" connections
 func (p *Pool) SetMax(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if n == 0 {
 		p.cfg.Max = defaultPoolConfig.Max
 	} else {
 		p.cfg.Max = maxInt32(int32(n), 1)
 	}
+
+	// Allocate any new capacity to waiting callers
+	for len(p.waiters) > 0 && p.open < p.cfg.Max {
+		p.next()
+	}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
-func (p *Pool) new() (SQConnection, error) {
-	// If pool is being drained, return nil
-	if atomic.LoadInt32(&p.drain) != 0 {
-		return nil, nil
+// new opens a connection for the pool. In WAL mode, the connection is
+// read-only and transactions which write are performed on the writer
+func (p *Pool) new() (*Conn, error) {
+	flags, writer := p.cfg.Flags, p.currentWriter()
+	if writer != nil {
+		flags &^= SQFlag(sqlite3.SQLITE_OPEN_READWRITE | sqlite3.SQLITE_OPEN_CREATE)
+		flags |= SQFlag(sqlite3.SQLITE_OPEN_READONLY)
+	}
+	conn, err := p.openConn(flags)
+	if err != nil {
+		return nil, err
+	}
+	conn.writer = writer
+	return conn, nil
+}
+
+// newWriter opens the writer connection and puts each database into
+// WAL mode
+func (p *Pool) newWriter() (*Conn, error) {
+	conn, err := p.openConn(p.cfg.Flags | SQFlag(sqlite3.SQLITE_OPEN_READWRITE))
+	if err != nil {
+		return nil, err
 	}
+	if err := conn.internal(func() error {
+		for _, schema := range conn.Schemas() {
+			if err := setJournalModeWAL(conn, schema); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
 
-	// If cur >= max, then reject
-	if p.cfg.Max != 0 && atomic.LoadInt32(&p.n) >= p.cfg.Max {
-		return nil, ErrChannelBlocked.Withf("Maximum number of connections reached (%d)", p.cfg.Max)
+// setJournalModeWAL puts a schema into WAL mode
+func setJournalModeWAL(conn *Conn, schema string) error {
+	var mode string
+	if err := conn.Exec(Q("PRAGMA ", N(schema), ".journal_mode=WAL"), func(row, _ []string) bool {
+		mode = row[0]
+		return false
+	}); err != nil {
+		return err
+	} else if mode != "wal" && schema != tempSchema {
+		return ErrInternalAppError.Withf("Schema %q: unable to set WAL mode (mode is %q)", schema, mode)
 	}
+	return nil
+}
 
+// openConn opens a connection with flags, attach schemas and set hooks
+func (p *Pool) openConn(flags SQFlag) (*Conn, error) {
 	// Open connection to main schema, which is required
-	defaultPath := p.pathForSchema(DefaultSchema)
+	schemas, generation := p.schemasForConn()
+	defaultPath := schemas[DefaultSchema]
 	if defaultPath == "" {
 		return nil, ErrNotFound.Withf("No default schema %q found", DefaultSchema)
 	}
 
 	// Always allow memory databases to be created and read/write
-	flags := p.cfg.Flags
 	if defaultPath == defaultMemory {
 		flags |= SQFlag(sqlite3.SQLITE_OPEN_CREATE | sqlite3.SQLITE_OPEN_READWRITE)
 	}
@@ -266,19 +807,44 @@ func (p *Pool) new() (SQConnection, error) {
 		return nil, err
 	}
 
-	// Set trace
+	// Set cache size
+	if flags&SQLITE_OPEN_CACHE != 0 && p.cfg.CacheSize > 0 {
+		conn.SetCap(p.cfg.CacheSize)
+	}
+
+	// Set busy timeout and retry policy
+	if p.cfg.BusyTimeout > 0 {
+		if err := conn.SetBusyTimeout(p.cfg.BusyTimeout); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	conn.SetRetryPolicy(p.cfg.Retry)
+
+	// Set trace function, which is called when each statement has
+	// completed, and tracers
+	var t tracers
 	if p.cfg.Trace != nil {
-		conn.ConnEx.SetTraceHook(func(_ sqlite3.TraceType, a, b unsafe.Pointer) int {
-			p.trace(conn, (*sqlite3.Statement)(a), *(*int64)(b))
-			return 0
-		}, sqlite3.SQLITE_TRACE_PROFILE)
+		t = append(t, tracefunc{conn, p.cfg.Trace})
+	}
+	if p.cfg.Tracer != nil {
+		t = append(t, p.cfg.Tracer)
+	}
+	if p.profile != nil {
+		t = append(t, p.profile)
 	}
+	if len(t) == 1 {
+		conn.SetTracer(t[0])
+	} else if len(t) > 1 {
+		conn.SetTracer(t)
+	}
+
+	// Publish committed changes to subscribers
+	conn.SetChangeHook(p.publish)
 
 	// Attach additional databases
 	var result error
-	for schema := range p.cfg.Schemas {
-		schema = strings.TrimSpace(schema)
-		path := p.pathForSchema(schema)
+	for schema, path := range schemas {
 		if schema == DefaultSchema {
 			continue
 		}
@@ -288,21 +854,35 @@ func (p *Pool) new() (SQConnection, error) {
 			result = multierror.Append(result, err)
 		}
 	}
+	conn.schemas = generation
 
 	// Set auth
 	if p.cfg.Auth != nil {
 		conn.SetAuthorizerHook(func(action sqlite3.SQAction, args [4]string) sqlite3.SQAuth {
-			if err := p.auth(conn.ctx, action, args); err == nil {
-				return sqlite3.SQLITE_ALLOW
-			} else {
-				p.err(err)
-				return sqlite3.SQLITE_DENY
-			}
+			return p.authorize(conn, action, args)
 		})
 	}
 
+	// Register extensions and call connect hook, which are not authorized
+	if result == nil {
+		if err := conn.internal(func() error {
+			for _, extension := range p.cfg.Extensions {
+				if err := extension.Register(conn); err != nil {
+					return fmt.Errorf("%v: %w", extension, err)
+				}
+			}
+			if p.cfg.OnConnect != nil {
+				return p.cfg.OnConnect(conn)
+			}
+			return nil
+		}); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
 	// Check for errors
 	if result != nil {
+		conn.Close()
 		return nil, result
 	}
 
@@ -310,6 +890,180 @@ func (p *Pool) new() (SQConnection, error) {
 	return conn, nil
 }
 
+// create opens a new connection for a caller which has been allocated
+// a slot in the pool, and releases the slot if the connection fails
+func (p *Pool) create() (*Conn, error) {
+	conn, err := p.new()
+	if err == nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.conns[conn] = true
+		return conn, nil
+	}
+
+	// Release the slot, and pass it to the next waiting caller
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.open--
+	atomic.AddInt32(&p.n, -1)
+	p.next()
+	return nil, err
+}
+
+// release returns a checked out connection to the pool, either handing it
+// to the next waiting caller, putting it on the free list or closing it.
+// The caller must hold the lock.
+func (p *Pool) release(conn *Conn) {
+	atomic.AddInt32(&p.n, -1)
+	conn.idle = time.Now()
+	expired := p.expired(conn, conn.idle)
+	switch {
+	case atomic.LoadInt32(&p.drain) != 0:
+		p.discard(conn, ClosedPool)
+	case p.open > p.cfg.Max:
+		p.discard(conn, ClosedMaxConnections)
+	case expired != "":
+		p.discard(conn, expired)
+	case len(p.waiters) > 0:
+		w := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		atomic.AddInt32(&p.n, 1)
+		p.checkouts++
+		w <- conn
+	case p.cfg.MaxIdle > 0 && int32(len(p.free)) >= p.cfg.MaxIdle:
+		p.discard(conn, ClosedMaxIdle)
+	default:
+		p.free = append(p.free, conn)
+	}
+}
+
+// waited adds to the total time callers have waited for a connection
+func (p *Pool) waited(start time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wait += time.Since(start)
+}
+
+// discard closes a connection which is not checked out, and allocates
+// the slot to the next waiting caller. The caller must hold the lock.
+func (p *Pool) discard(conn *Conn, reason string) {
+	p.open--
+	p.closed[reason]++
+	delete(p.conns, conn)
+	if err := conn.Close(); err != nil {
+		p.err(err)
+	}
+	if err := p.closeWriter(); err != nil {
+		p.err(err)
+	}
+	p.next()
+}
+
+// expired returns the reason a connection should be closed if it has
+// exceeded the maximum lifetime or has been idle for longer than the idle
+// timeout, or an empty string otherwise
+func (p *Pool) expired(conn *Conn, now time.Time) string {
+	if p.cfg.MaxLifetime > 0 && now.Sub(conn.opened) > p.cfg.MaxLifetime {
+		return ClosedMaxLifetime
+	}
+	if p.cfg.IdleTimeout > 0 && now.Sub(conn.idle) > p.cfg.IdleTimeout {
+		return ClosedIdleTimeout
+	}
+	return ""
+}
+
+// ping checks a connection is healthy, if enabled
+func (p *Pool) ping(conn *Conn) error {
+	if !p.cfg.Ping {
+		return nil
+	}
+	return conn.internal(func() error {
+		return conn.Exec(Q("SELECT 1"), nil)
+	})
+}
+
+// reapInterval returns the interval for checking idle connections, or
+// zero if connections never expire
+func (p *Pool) reapInterval() time.Duration {
+	interval := durationMax(p.cfg.IdleTimeout, p.cfg.MaxLifetime)
+	if p.cfg.IdleTimeout > 0 {
+		interval = durationMin(interval, p.cfg.IdleTimeout)
+	}
+	if p.cfg.MaxLifetime > 0 {
+		interval = durationMin(interval, p.cfg.MaxLifetime)
+	}
+	return interval / 2
+}
+
+// reap closes idle connections which have expired, until stopped
+func (p *Pool) reap(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			p.mu.Lock()
+			free := p.free[:0]
+			for _, conn := range p.free {
+				if reason := p.expired(conn, now); reason != "" {
+					p.discard(conn, reason)
+				} else {
+					free = append(free, conn)
+				}
+			}
+			p.free = free
+			p.mu.Unlock()
+		}
+	}
+}
+
+// next allocates a slot for a new connection to the next waiting caller,
+// if there is capacity. The caller must hold the lock.
+func (p *Pool) next() {
+	if len(p.waiters) == 0 || p.open >= p.cfg.Max || atomic.LoadInt32(&p.drain) != 0 {
+		return
+	}
+	w := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	p.open++
+	atomic.AddInt32(&p.n, 1)
+	p.checkouts++
+	w <- nil
+}
+
+// dequeue removes a caller from the wait queue, and returns false if the
+// caller was no longer waiting. The caller must hold the lock.
+func (p *Pool) dequeue(w chan *Conn) bool {
+	for i := range p.waiters {
+		if p.waiters[i] == w {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// currentWriter returns the writer connection in WAL mode, or nil. The writer
+// is closed under the lock, so it is read under the lock.
+func (p *Pool) currentWriter() *Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.writer
+}
+
+// closeWriter closes the writer connection when the pool is drained and
+// there are no other open connections. The caller must hold the lock.
+func (p *Pool) closeWriter() error {
+	if p.writer == nil || p.open > 0 || atomic.LoadInt32(&p.drain) == 0 {
+		return nil
+	}
+	writer := p.writer
+	p.writer = nil
+	return writer.Close()
+}
+
 // err will pass an error to a channel unless channel is blocked
 func (p *Pool) err(err error) {
 	if p.errs != nil {
@@ -329,16 +1083,73 @@ func (p *Pool) pathForSchema(schema string) string {
 		return p.pathForSchema(DefaultSchema)
 	} else if !reSchemaName.MatchString(schema) {
 		return ""
-	} else if path, exists := p.cfg.Schemas[schema]; !exists {
+	}
+	p.smx.RLock()
+	defer p.smx.RUnlock()
+	if path, exists := p.cfg.Schemas[schema]; !exists {
 		return ""
 	} else {
 		return path
 	}
 }
 
-// Trace
-func (p *Pool) trace(c *Conn, s *sqlite3.Statement, ns int64) {
-	if p.cfg.Trace != nil {
-		p.cfg.Trace(c, s.SQL(), time.Duration(ns)*time.Nanosecond)
+// schemasForConn returns the valid schema names mapped onto paths, and the
+// generation of the schemas, which changes when schemas are attached
+// or detached
+func (p *Pool) schemasForConn() (map[string]string, int64) {
+	p.smx.RLock()
+	defer p.smx.RUnlock()
+	result := make(map[string]string, len(p.cfg.Schemas))
+	for schema, path := range p.cfg.Schemas {
+		if reSchemaName.MatchString(schema) {
+			result[schema] = path
+		} else {
+			result[schema] = ""
+		}
+	}
+	return result, p.schemas
+}
+
+// syncSchemas attaches and detaches schemas on a checked out connection
+// when the pool schemas have changed since the connection was opened or
+// last checked out
+func (p *Pool) syncSchemas(conn *Conn) error {
+	schemas, generation := p.schemasForConn()
+	if conn.schemas == generation {
+		return nil
+	}
+	conn.noauth = true
+	defer func() { conn.noauth = false }()
+
+	// Detach schemas which have been removed or moved
+	var result error
+	attached := make(map[string]bool)
+	for _, schema := range conn.Schemas() {
+		if schema == DefaultSchema || schema == tempSchema {
+			continue
+		}
+		if path, exists := schemas[schema]; exists && samePath(conn.Filename(schema), path) {
+			attached[schema] = true
+		} else if err := conn.Detach(schema); err != nil {
+			result = multierror.Append(result, err)
+		}
 	}
+
+	// Attach new schemas
+	for schema, path := range schemas {
+		if schema == DefaultSchema || attached[schema] {
+			continue
+		}
+		if path == "" {
+			result = multierror.Append(result, ErrBadParameter.Withf("Schema %q", schema))
+		} else if err := conn.Attach(schema, path); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	// Return any errors
+	if result == nil {
+		conn.schemas = generation
+	}
+	return result
 }
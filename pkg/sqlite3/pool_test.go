@@ -2,8 +2,11 @@ package sqlite3_test
 
 import (
 	"context"
+	"errors"
 	"math/rand"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -70,6 +73,216 @@ func Test_Pool_002(t *testing.T) {
 	cancel()
 }
 
+func Test_Pool_003(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+	pool, err := OpenPool(NewConfig().WithMaxConnections(1).WithTimeout(100*time.Millisecond), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Check out the only connection, then wait for it with a timeout
+	conn, err := pool.GetContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.GetContext(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected deadline exceeded, got", err)
+	}
+
+	// Waiters should receive the connection in order
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	order := []int{}
+	ctx, cancelwait := context.WithTimeout(context.Background(), time.Second)
+	defer cancelwait()
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := pool.GetContext(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			pool.Put(conn)
+		}(i)
+		// Ensure the callers are queued in order
+		time.Sleep(10 * time.Millisecond)
+	}
+	pool.Put(conn)
+	wg.Wait()
+	for i, v := range order {
+		if i != v {
+			t.Error("Unexpected order", order)
+			break
+		}
+	}
+	if pool.Cur() != 0 {
+		t.Error("Unexpected cur", pool.Cur())
+	}
+}
+
+func Test_Pool_004(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+
+	// Memory databases are not supported in WAL mode
+	if _, err := OpenPool(NewConfig().WithWAL(true), errs); err == nil {
+		t.Error("Expected error for memory database")
+	}
+
+	path := filepath.Join(t.TempDir(), "test.sqlite")
+	pool, err := OpenPool(NewConfig().WithSchema("main", path).WithWAL(true), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+
+	// Writes are routed to the writer
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		if _, err := txn.Query(N("test").CreateTable(C("a").WithType("INTEGER"))); err != nil {
+			return err
+		}
+		_, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads are performed on the read-only connection, and are not blocked
+	// by a write transaction
+	if err := conn.Do(context.Background(), SQLITE_TXN_IMMEDIATE, func(SQTransaction) error {
+		return conn.Do(context.Background(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
+			if n := txn.Count("main", "test"); n != 1 {
+				t.Error("Unexpected count", n)
+			}
+			if _, err := txn.Query(Q("INSERT INTO test (a) VALUES (2)")); err == nil {
+				t.Error("Expected error writing on a read-only transaction")
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Pool_005(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+
+	// Count new connections, and check the hook is called on each one
+	var connects int32
+	cfg := NewConfig().WithMaxIdle(1).WithIdleTimeout(50 * time.Millisecond).WithPing(true).WithOnConnect(func(conn *Conn) error {
+		atomic.AddInt32(&connects, 1)
+		return conn.Exec(Q("PRAGMA cache_size=100"), nil)
+	})
+	pool, err := OpenPool(cfg, errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Check out two connections and return them, only one is kept idle
+	a, b := pool.Get(), pool.Get()
+	if a == nil || b == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	pool.Put(a)
+	pool.Put(b)
+	if n := atomic.LoadInt32(&connects); n != 2 {
+		t.Error("Unexpected connects", n)
+	}
+	a, b = pool.Get(), pool.Get()
+	pool.Put(a)
+	pool.Put(b)
+	if n := atomic.LoadInt32(&connects); n != 3 {
+		t.Error("Unexpected connects", n)
+	}
+
+	// Idle connections are closed after the timeout
+	time.Sleep(150 * time.Millisecond)
+	a = pool.Get()
+	pool.Put(a)
+	if n := atomic.LoadInt32(&connects); n != 4 {
+		t.Error("Unexpected connects", n)
+	}
+
+	// Connections are closed when the hook returns an error
+	pool2, err := OpenPool(NewConfig().WithOnConnect(func(*Conn) error {
+		return errors.New("connect error")
+	}), nil)
+	if err == nil {
+		pool2.Close()
+		t.Error("Expected error from connect hook")
+	}
+}
+
+func Test_Pool_006(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+
+	dir := t.TempDir()
+	pool, err := OpenPool(NewConfig().WithSchema("main", filepath.Join(dir, "main.sqlite")).WithWAL(true), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// A connection checked out before the attach is not changed
+	a := pool.Get()
+	if a == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	if err := pool.Attach("other", filepath.Join(dir, "other.sqlite")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Attach("other", filepath.Join(dir, "other.sqlite")); err == nil {
+		t.Error("Expected error for duplicate schema")
+	}
+	if schemas := a.Schemas(); len(schemas) != 1 {
+		t.Error("Unexpected schemas", schemas)
+	}
+
+	// Writes go to the writer, which has the schema attached, and the schema
+	// is attached to the connection on the next checkout
+	if err := a.Do(context.Background(), 0, func(txn SQTransaction) error {
+		_, err := txn.Query(N("test").WithSchema("other").CreateTable(C("a")))
+		return err
+	}); err != nil {
+		t.Error(err)
+	}
+	pool.Put(a)
+	a = pool.Get()
+	if tables := a.Tables("other"); len(tables) != 1 {
+		t.Error("Unexpected tables", tables)
+	}
+
+	// Detach the schema, which is detached on next checkout
+	if err := pool.Detach("other"); err != nil {
+		t.Error(err)
+	}
+	if err := pool.Detach("other"); err == nil {
+		t.Error("Expected error for unknown schema")
+	}
+	pool.Put(a)
+	a = pool.Get()
+	if schemas := a.Schemas(); len(schemas) != 1 {
+		t.Error("Unexpected schemas", schemas)
+	}
+	pool.Put(a)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
@@ -77,6 +290,38 @@ func randomDuration(max time.Duration) time.Duration {
 	return time.Duration(time.Duration(rand.Int63()) % time.Duration(max))
 }
 
+func Test_Pool_007(t *testing.T) {
+	pool, err := OpenPool(NewConfig().WithMaxConnections(1).WithTimeout(100*time.Millisecond), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Get waits for the timeout when all connections are checked out, and
+	// then returns nil
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	start := time.Now()
+	if conn := pool.Get(); conn != nil {
+		t.Error("Expected nil connection")
+	} else if d := time.Since(start); d < 100*time.Millisecond {
+		t.Error("Expected Get to wait for the timeout, waited", d)
+	}
+
+	// Get returns a connection released while waiting
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		pool.Put(conn)
+	}()
+	if conn := pool.Get(); conn == nil {
+		t.Error("Expected connection to be released")
+	} else {
+		pool.Put(conn)
+	}
+}
+
 func handleErrors(t *testing.T) (chan<- error, context.CancelFunc) {
 	var wg sync.WaitGroup
 	errs := make(chan error)
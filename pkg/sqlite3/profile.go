@@ -3,6 +3,7 @@ package sqlite3
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -22,25 +23,27 @@ type samplearray struct {
 	samples []sample
 	cap     int
 	n       int
+	total   int       // Total number of samples added
+	first   time.Time // Time of the first sample added
 }
 
 type profilearray struct {
 	sync.RWMutex
-	sync.WaitGroup
 	m   map[string]*samplearray
 	cap int
 	n   int
 	age time.Duration
 }
 
-type profilesample struct {
+// QueryProfile holds execution time statistics for a normalized statement
+type QueryProfile struct {
 	key            string
 	count          int
 	delta          time.Duration
 	min, mean, max time.Duration
 }
 
-type samplearr []*profilesample
+type samplearr []*QueryProfile
 
 ////////////////////////////////////////////////////////////////////////////////
 // GLOBALS
@@ -79,8 +82,9 @@ func NewProfileArray(cap, samples int, age time.Duration) *profilearray {
 }
 
 func (p *profilearray) Close() {
-	// Wait for garbage collection to complete
-	p.Wait()
+	p.RWMutex.Lock()
+	defer p.RWMutex.Unlock()
+
 	// Release resources
 	p.m = nil
 }
@@ -97,51 +101,62 @@ func (a *samplearray) String() string {
 	return str + ">"
 }
 
-func (s *profilesample) String() string {
+func (s *QueryProfile) String() string {
 	str := "<sample"
 	str += fmt.Sprintf(" key=%q", s.key)
-	str += fmt.Sprint(" min=", s.min.Truncate(time.Millisecond))
-	str += fmt.Sprint(" mean=", s.mean.Truncate(time.Millisecond))
-	str += fmt.Sprint(" max=", s.max.Truncate(time.Millisecond))
+	str += fmt.Sprint(" min=", s.min.Truncate(time.Microsecond))
+	str += fmt.Sprint(" mean=", s.mean.Truncate(time.Microsecond))
+	str += fmt.Sprint(" max=", s.max.Truncate(time.Microsecond))
 	str += fmt.Sprint(" count=", s.count)
 	str += fmt.Sprint(" delta=", s.delta)
-	str += fmt.Sprintf(" ops/s=%.1f", float64(s.count)/s.delta.Seconds())
+	if s.delta > 0 {
+		str += fmt.Sprintf(" ops/s=%.1f", s.Rate())
+	}
 	return str + ">"
 }
 
 ////////////////////////////////////////////////////////////////////////////////
-// PUBLIC METHODS - PROFILE ARRAY
+// PUBLIC METHODS - PROFILE SAMPLE
 
 // Return the query text
-func (s *profilesample) SQL() string {
+func (s *QueryProfile) SQL() string {
 	return s.key
 }
 
 // Return the minimum query time
-func (s *profilesample) Min() time.Duration {
+func (s *QueryProfile) Min() time.Duration {
 	return s.min
 }
 
 // Return the maximum query time
-func (s *profilesample) Max() time.Duration {
+func (s *QueryProfile) Max() time.Duration {
 	return s.max
 }
 
 // Return the mean average query time
-func (s *profilesample) Mean() time.Duration {
+func (s *QueryProfile) Mean() time.Duration {
 	return s.mean
 }
 
-// Return the number of samples
-func (s *profilesample) Count() int {
+// Return the number of times the query was executed
+func (s *QueryProfile) Count() int {
 	return s.count
 }
 
 // Return the period over which the samples were taken
-func (s *profilesample) Delta() time.Duration {
+func (s *QueryProfile) Delta() time.Duration {
 	return s.delta
 }
 
+// Return the number of times the query was executed per second, or zero
+// if the period is zero
+func (s *QueryProfile) Rate() float64 {
+	if s.delta <= 0 {
+		return 0
+	}
+	return float64(s.count) / s.delta.Seconds()
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS - PROFILE ARRAY
 
@@ -149,6 +164,9 @@ func (s *profilesample) Delta() time.Duration {
 func (p *profilearray) Add(key string, d time.Duration) {
 	p.RWMutex.Lock()
 	defer p.RWMutex.Unlock()
+	if p.m == nil {
+		return
+	}
 	if _, exists := p.m[key]; !exists {
 		p.m[key] = NewSampleArray(p.n)
 	}
@@ -157,55 +175,66 @@ func (p *profilearray) Add(key string, d time.Duration) {
 	// Cull the least used profiles by date and by oldest once we
 	// reach capacity
 	if len(p.m) > p.cap {
-		p.WaitGroup.Add(1)
-		go func() {
-			defer p.WaitGroup.Done()
-			p.garbagecollect(p.cap >> 1)
-		}()
+		p.garbagecollect(p.cap >> 1)
 	}
 }
 
 // Return n profiles - key, delta, count and mean - sorted by mean so the
 // slowest queries are first. Stats are then ops per second and
 // mean time taken per query.
-func (p *profilearray) SlowQueries(n int) []*profilesample {
-	// Check arguments
-	if n < 1 {
-		return nil
-	}
-	// Populate results
-	results := make(samplearr, 0, len(p.m))
-	p.RWMutex.RLock()
-	for key, samples := range p.m {
-		results = append(results, samples.NewSlowQuery(key))
-	}
-	p.RWMutex.RUnlock()
-
-	// Sort results with the slowest queries first
+func (p *profilearray) SlowQueries(n int) []*QueryProfile {
+	results := p.queries(n)
 	sort.Sort(results)
+	return results[:intMin(n, len(results))]
+}
+
+// Return n profiles sorted by count so the most frequently executed
+// queries are first
+func (p *profilearray) FrequentQueries(n int) []*QueryProfile {
+	results := p.queries(n)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].count > results[j].count
+	})
+	return results[:intMin(n, len(results))]
+}
 
-	// Return top n results
-	n = intMin(n, len(p.m))
-	return results[:n]
+// StartSpan is called when a statement starts executing
+func (p *profilearray) StartSpan(*Span) {
+	// Profiles are only added when a statement is completed
+}
+
+// EndSpan adds the statement duration to the profile for the normalized
+// statement
+func (p *profilearray) EndSpan(span *Span) {
+	if span.SQL != "" {
+		p.Add(normalizeSQL(span.SQL), span.Duration)
+	}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS - SAMPLE ARRAY
 
-func (a *samplearray) NewSlowQuery(key string) *profilesample {
-	s := new(profilesample)
+func (a *samplearray) NewSlowQuery(key string) *QueryProfile {
+	s := new(QueryProfile)
 	s.key = key
-	s.count = len(a.samples)
-	s.delta = a.Delta()
+	s.count = a.total
+	if len(a.samples) > 0 {
+		s.delta = a.samples[a.i(-1)].t.Sub(a.first)
+	}
 
-	var sum float64
+	var sum time.Duration
 	for i := 0; i < len(a.samples); i++ {
 		v := a.samples[a.i(i)].d
 		if i == 0 {
+			s.min, s.max = v, v
+		} else {
 			s.min, s.max = durationMin(v, s.min), durationMax(v, s.max)
 		}
+		sum += v
+	}
+	if len(a.samples) > 0 {
+		s.mean = sum / time.Duration(len(a.samples))
 	}
-	s.mean = time.Duration(sum / float64(s.count))
 	return s
 }
 
@@ -229,6 +258,10 @@ func (a *samplearray) Add(d time.Duration) {
 		a.samples = a.samples[:a.n+1]
 	}
 	a.samples[a.n] = sample{t: time.Now(), d: d}
+	if a.total == 0 {
+		a.first = a.samples[a.n].t
+	}
+	a.total++
 	a.n = a.n + 1
 	if a.n >= a.cap {
 		a.n = 0
@@ -246,32 +279,41 @@ func (a *samplearray) Delta() time.Duration {
 ////////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS - PROFILE ARRAY
 
-// Remove the profiles down to "cap" profiles, removing oldest profiles first
-func (p *profilearray) garbagecollect(cap int) {
-	// Remove by age
-	keys := make([]string, 0, cap)
+// Return the statistics for each profile, or nil if n is less than one
+func (p *profilearray) queries(n int) samplearr {
+	if n < 1 {
+		return nil
+	}
 	p.RWMutex.RLock()
-	for key, sample := range p.m {
-		if len(keys) < cap {
-			if sample.Last() > p.age {
-				keys = append(keys, key)
-			}
-		}
+	defer p.RWMutex.RUnlock()
+	results := make(samplearr, 0, len(p.m))
+	for key, samples := range p.m {
+		results = append(results, samples.NewSlowQuery(key))
 	}
-	p.RWMutex.RUnlock()
+	return results
+}
 
-	// Remove keys
-	if len(keys) > 0 {
-		p.RWMutex.Lock()
-		for _, key := range keys {
+// Remove the profiles down to "cap" profiles, removing profiles older than
+// the maximum age and then the least recently used profiles. The caller
+// should hold the write lock.
+func (p *profilearray) garbagecollect(cap int) {
+	keys := make([]string, 0, len(p.m))
+	for key, samples := range p.m {
+		if samples.Last() > p.age {
 			delete(p.m, key)
+		} else {
+			keys = append(keys, key)
 		}
-		p.RWMutex.Unlock()
 	}
 
-	// TODO: Cull by query time - sort samples by mean and remove oldest N
+	// Remove least recently used
 	if len(p.m) > cap {
-		fmt.Println("TODO: required cap ", cap, " actual cap", len(p.m))
+		sort.Slice(keys, func(i, j int) bool {
+			return p.m[keys[i]].Last() > p.m[keys[j]].Last()
+		})
+		for _, key := range keys[:len(p.m)-cap] {
+			delete(p.m, key)
+		}
 	}
 }
 
@@ -298,3 +340,29 @@ func (a *samplearray) i(i int) int {
 	}
 	return j
 }
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// normalizeSQL returns a statement with string and numeric literals replaced
+// by parameters, and whitespace and comments replaced by a single space, so
+// that statements which differ only by literal values share a profile
+func normalizeSQL(sql string) string {
+	var str strings.Builder
+	end := 0
+	for i, token := range ddlTokenize(sql) {
+		if i > 0 && token.pos > end {
+			str.WriteByte(' ')
+		}
+		switch {
+		case token.kind == ddlString:
+			str.WriteByte('?')
+		case token.kind == ddlWord && token.value[0] >= '0' && token.value[0] <= '9':
+			str.WriteByte('?')
+		default:
+			str.WriteString(sql[token.pos:token.end])
+		}
+		end = token.end
+	}
+	return str.String()
+}
@@ -0,0 +1,65 @@
+package sqlite3_test
+
+import (
+	"fmt"
+	"testing"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+func Test_Profile_001(t *testing.T) {
+	tracer := new(SpanTracer)
+	pool, err := OpenPool(NewConfig().WithProfile(true).WithTracer(tracer), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Statements which differ by literal values share a profile
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	for i := 0; i < 10; i++ {
+		r, err := conn.Query(Q(fmt.Sprintf("SELECT %d,  'value %d' -- comment", i, i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for row := r.Next(); row != nil; row = r.Next() {
+		}
+	}
+
+	// Spans end when the next statement starts
+	if err := conn.Exec(Q("SELECT NULL"), nil); err != nil {
+		t.Error(err)
+	}
+	pool.Put(conn)
+
+	// Check the most frequent query
+	if queries := pool.FrequentQueries(1); len(queries) != 1 {
+		t.Fatal("Unexpected queries", queries)
+	} else if sql := queries[0].SQL(); sql != "SELECT ?, ?" {
+		t.Errorf("Unexpected SQL %q", sql)
+	} else if n := queries[0].Count(); n != 10 {
+		t.Error("Unexpected count", n)
+	} else if queries[0].Min() > queries[0].Mean() || queries[0].Mean() > queries[0].Max() {
+		t.Error("Unexpected statistics", queries[0])
+	} else {
+		t.Log(queries[0])
+	}
+
+	// Check slow queries are ordered by mean
+	queries := pool.SlowQueries(100)
+	for i := 1; i < len(queries); i++ {
+		if queries[i].Mean() > queries[i-1].Mean() {
+			t.Error("Unexpected order", queries)
+		}
+	}
+
+	// Spans are also passed to the configured tracer
+	if len(tracer.spans) == 0 {
+		t.Error("Expected spans")
+	}
+}
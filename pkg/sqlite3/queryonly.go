@@ -0,0 +1,32 @@
+package sqlite3
+
+import (
+	// Import namespaces
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// QueryOnly returns true if changes to database files are prevented
+func (this *Conn) QueryOnly() (bool, error) {
+	var enable bool
+	if err := this.Exec(Q("PRAGMA query_only"), func(row, _ []string) bool {
+		enable = stringToBool(row[0])
+		return false
+	}); err != nil {
+		return false, err
+	}
+	// Return success
+	return enable, nil
+}
+
+// SetQueryOnly prevents or allows changes to database files
+func (this *Conn) SetQueryOnly(enable bool) error {
+	if v, err := this.QueryOnly(); err != nil {
+		return err
+	} else if v == enable {
+		return nil
+	}
+	return this.Exec(Q("PRAGMA query_only=", V(enable)), nil)
+}
@@ -0,0 +1,137 @@
+package sqlite3_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+func Test_QueryOnly_001(t *testing.T) {
+	conn, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.Exec(N("test").CreateTable(C("a").WithType("INTEGER")), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads are allowed and writes fail in a read-only transaction
+	if err := conn.Do(context.Background(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
+		if _, err := txn.Query(Q("SELECT * FROM test")); err != nil {
+			return err
+		}
+		if _, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)")); !errors.Is(err, sqlite3.SQLITE_READONLY) {
+			t.Error("Expected SQLITE_READONLY error, got", err)
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	// query_only is restored after the transaction
+	if v, err := conn.QueryOnly(); err != nil {
+		t.Error(err)
+	} else if v {
+		t.Error("Expected query_only to be false")
+	}
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		_, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)"))
+		return err
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_QueryOnly_002(t *testing.T) {
+	// Memory databases are not supported in read-only mode
+	if _, err := OpenPool(NewConfig().WithReadOnly(true), nil); err == nil {
+		t.Error("Expected error for memory database")
+	}
+
+	// Create a database
+	path := filepath.Join(t.TempDir(), "test.sqlite")
+	if conn, err := OpenPath(path, SQFlag(sqlite3.DefaultFlags)); err != nil {
+		t.Fatal(err)
+	} else if err := conn.Exec(N("test").CreateTable(C("a").WithType("INTEGER")), nil); err != nil {
+		t.Fatal(err)
+	} else {
+		conn.Close()
+	}
+
+	// Open a read-only pool, which cannot modify the database
+	pool, err := OpenPool(NewConfig().WithSchema(DefaultSchema, path).WithReadOnly(true), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		_, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)"))
+		return err
+	}); !errors.Is(err, sqlite3.SQLITE_READONLY) {
+		t.Error("Expected SQLITE_READONLY error, got", err)
+	}
+	if err := conn.Do(context.Background(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
+		_, err := txn.Query(Q("SELECT * FROM test"))
+		return err
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_QueryOnly_003(t *testing.T) {
+	conn, err := OpenPath(":memory:", SQFlag(sqlite3.DefaultFlags)|SQLITE_OPEN_CACHE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.Exec(N("test").CreateTable(C("a").WithType("INTEGER")), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Prepare a statement which changes query_only outside a read-only
+	// transaction, so that it is cached
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		_, err := txn.Query(Q("PRAGMA query_only=0"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// query_only cannot be switched off in a read-only transaction
+	for _, st := range []string{"PRAGMA query_only=0", "PRAGMA main.QUERY_ONLY=false"} {
+		if err := conn.Do(context.Background(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
+			if _, err := txn.Query(Q(st)); err == nil {
+				t.Errorf("Expected error for %q", st)
+			}
+			if _, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)")); !errors.Is(err, sqlite3.SQLITE_READONLY) {
+				t.Error("Expected SQLITE_READONLY error, got", err)
+			}
+			return nil
+		}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// query_only can be changed outside a read-only transaction
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		_, err := txn.Query(Q("PRAGMA query_only=0"))
+		return err
+	}); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,12 +1,15 @@
 package sqlite3
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"sync"
 
 	// Packages
+	scan "github.com/mutablelogic/go-sqlite/pkg/scan"
 	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
 
 	// Namespace imports
@@ -21,7 +24,13 @@ import (
 type Results struct {
 	st      *sqlite3.StatementEx
 	results *sqlite3.Results
-	n       uint // next statement to execute
+	n       uint       // next statement to execute
+	conn    *Conn      // connection for tracing, or nil
+	cache   *ConnCache // cache which holds the statement, or nil
+
+	// Outside a transaction, the connection is locked whilst stepping
+	mu  *sync.Mutex     // connection lock, or nil within a transaction
+	ctx context.Context // context which cancels a statement in progress
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -34,12 +43,21 @@ func NewResults(st *sqlite3.StatementEx) *Results {
 }
 
 func (r *Results) Close() error {
-	// Only free prepared statements if they are not cached
+	if r.mu != nil {
+		defer r.lock()()
+	}
+
+	// Only free prepared statements if they are not cached, otherwise
+	// reset them and release them so they can be evicted from the cache
 	if !r.st.Cached() {
 		return r.st.Close()
-	} else {
-		return r.st.Close()
 	}
+	err := r.st.Reset()
+	if r.cache != nil {
+		r.cache.release(r.st)
+		r.cache = nil
+	}
+	return err
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -61,7 +79,23 @@ func (r *Results) String() string {
 // are no more statements. In order to read the rows, repeatedly read the rows
 // using the Next function.
 func (r *Results) NextQuery(v ...interface{}) error {
-	if results, err := r.st.Exec(r.n, v...); errors.Is(err, sqlite3.SQLITE_DONE) {
+	if r.mu != nil {
+		defer r.lock()()
+	}
+	results, err := r.st.Exec(r.n, v...)
+	if r.conn != nil && !errors.Is(err, sqlite3.SQLITE_DONE) {
+		r.conn.traceFlush(err)
+	}
+
+	// Outside a transaction, notify any committed changes
+	if r.mu != nil {
+		if errors.Is(err, sqlite3.SQLITE_DONE) {
+			r.conn.notify(nil)
+		} else {
+			r.conn.notify(err)
+		}
+	}
+	if errors.Is(err, sqlite3.SQLITE_DONE) {
 		return io.EOF
 	} else if err != nil {
 		return err
@@ -77,9 +111,11 @@ func (r *Results) NextQuery(v ...interface{}) error {
 func (r *Results) Next(t ...reflect.Type) []interface{} {
 	if r.results == nil {
 		return nil
-	} else {
-		return r.results.Next(t...)
 	}
+	if r.mu != nil {
+		defer r.lock()()
+	}
+	return r.results.Next(t...)
 }
 
 func (r *Results) ExpandedSQL() string {
@@ -130,3 +166,42 @@ func (r *Results) ColumnSource(i int) (string, string, string) {
 	}
 	return schema, table, name
 }
+
+// ScanStruct reads the next row into v, which should be a pointer to a struct.
+// Columns are matched to fields by the sqlite tag name or else the field
+// name. Returns io.EOF when all rows have been read.
+func (r *Results) ScanStruct(v interface{}) error {
+	return scan.ScanStruct(r, v)
+}
+
+// ScanMap reads the next row into a map of column names to values.
+// Returns io.EOF when all rows have been read.
+func (r *Results) ScanMap() (map[string]interface{}, error) {
+	return scan.ScanMap(r)
+}
+
+// ScanAll reads all remaining rows into v, which should be a pointer to a
+// slice of structs or pointers to structs
+func (r *Results) ScanAll(v interface{}) error {
+	return scan.ScanAll(r, v)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// lock the connection for results outside a transaction, interrupting any
+// statement in progress when the context is done, and return a function
+// which unlocks it
+func (r *Results) lock() func() {
+	r.mu.Lock()
+	r.conn.ctx = r.ctx
+	r.conn.reauthorize()
+	r.conn.SetProgressHandler(100, func() bool {
+		return r.ctx.Err() != nil
+	})
+	return func() {
+		r.conn.SetProgressHandler(0, nil)
+		r.conn.ctx = nil
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,137 @@
+package sqlite3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// RetryPolicy determines how a transaction is retried when it fails because
+// the database is busy or locked. The whole transaction function is run
+// again on each attempt, so it should not have side-effects outside of the
+// transaction, other than through OnCommit and OnRollback.
+type RetryPolicy struct {
+	MaxAttempts int           `yaml:"max_attempts"` // Maximum number of attempts, or zero or one to not retry
+	MinBackoff  time.Duration `yaml:"min_backoff"`  // Backoff before the second attempt
+	MaxBackoff  time.Duration `yaml:"max_backoff"`  // Maximum backoff between attempts
+	Jitter      float64       `yaml:"jitter"`       // Fraction of the backoff which is randomized, between zero and one
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	defaultRetryMinBackoff = 10 * time.Millisecond
+	defaultRetryMaxBackoff = time.Second
+	defaultRetryJitter     = 0.5
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// LIFECYCLE
+
+// NewRetryPolicy returns a retry policy with the maximum number of attempts,
+// with an exponential backoff between 10ms and one second and a jitter of
+// 50% of the backoff
+func NewRetryPolicy(attempts int) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: attempts,
+		MinBackoff:  defaultRetryMinBackoff,
+		MaxBackoff:  defaultRetryMaxBackoff,
+		Jitter:      defaultRetryJitter,
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (r RetryPolicy) String() string {
+	str := "<retry"
+	str += fmt.Sprint(" max_attempts=", r.MaxAttempts)
+	str += fmt.Sprint(" min_backoff=", r.MinBackoff)
+	str += fmt.Sprint(" max_backoff=", r.MaxBackoff)
+	str += fmt.Sprint(" jitter=", r.Jitter)
+	return str + ">"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// IsRetryable returns true if the error indicates the database was busy or
+// locked, and the transaction can be attempted again
+func IsRetryable(err error) bool {
+	var code sqlite3.SQError
+	if !errors.As(err, &code) {
+		return false
+	}
+	// Mask extended result codes, such as SQLITE_BUSY_SNAPSHOT
+	switch code & 0xFF {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	default:
+		return false
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// backoff returns the duration to wait after a number of failed attempts
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	min, max := r.MinBackoff, r.MaxBackoff
+	if min <= 0 {
+		min = defaultRetryMinBackoff
+	}
+	if max < min {
+		max = min
+	}
+
+	// Exponential backoff, doubling on each attempt
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d = d * 2
+	}
+	if d > max {
+		d = max
+	}
+
+	// Randomize part of the backoff
+	if jitter := r.Jitter; jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		delta := time.Duration(float64(d) * jitter)
+		d = d - delta + time.Duration(rand.Int63n(int64(delta)+1))
+	}
+
+	// Return the backoff
+	return d
+}
+
+// wait returns true if another attempt should be made after a failed
+// attempt, waiting for the backoff. Returns false if the error is not
+// retryable, the maximum number of attempts has been reached or the
+// context is done.
+func (r RetryPolicy) wait(ctx context.Context, attempt int, err error) bool {
+	if attempt >= r.MaxAttempts || !IsRetryable(err) {
+		return false
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(r.backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,161 @@
+package sqlite3_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+func Test_Retry_001(t *testing.T) {
+	if !IsRetryable(sqlite3.SQLITE_BUSY.With("test")) {
+		t.Error("Expected SQLITE_BUSY to be retryable")
+	}
+	if !IsRetryable(sqlite3.SQLITE_LOCKED) {
+		t.Error("Expected SQLITE_LOCKED to be retryable")
+	}
+	if IsRetryable(sqlite3.SQLITE_CONSTRAINT) {
+		t.Error("Expected SQLITE_CONSTRAINT not to be retryable")
+	}
+	if IsRetryable(errors.New("test")) {
+		t.Error("Expected error not to be retryable")
+	}
+}
+
+func Test_Retry_002(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sqlite")
+	flags := SQFlag(sqlite3.DefaultFlags)
+
+	// Two connections to the same database
+	a, err := OpenPath(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := OpenPath(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err := b.SetBusyTimeout(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Exec(N("test").CreateTable(C("a").WithType("INTEGER")), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold a write lock on the first connection
+	locked, done := make(chan struct{}), make(chan error)
+	go func() {
+		done <- a.Do(context.Background(), 0, func(txn SQTransaction) error {
+			close(locked)
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		})
+	}()
+	<-locked
+
+	// Without retry, the transaction fails immediately
+	if err := b.Do(context.Background(), 0, nil); !IsRetryable(err) {
+		t.Error("Expected retryable error, got", err)
+	}
+
+	// With retry, the transaction is attempted until the lock is released
+	var attempts int
+	b.SetRetryPolicy(NewRetryPolicy(100))
+	if err := b.Do(context.Background(), 0, func(txn SQTransaction) error {
+		attempts++
+		_, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)"))
+		return err
+	}); err != nil {
+		t.Error(err)
+	} else if attempts != 1 {
+		t.Error("Expected function to be called once the lock was acquired, attempts=", attempts)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_Retry_003(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sqlite")
+	flags := SQFlag(sqlite3.DefaultFlags)
+
+	// Two connections to the same database
+	a, err := OpenPath(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := OpenPath(path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err := b.SetBusyTimeout(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Exec(N("test").CreateTable(C("a").WithType("INTEGER")), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold a write lock on the first connection
+	locked, done := make(chan struct{}), make(chan error)
+	go func() {
+		done <- a.Do(context.Background(), 0, func(txn SQTransaction) error {
+			close(locked)
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		})
+	}()
+	<-locked
+
+	// Deferred transactions fail within the function, and the functions
+	// registered by retried attempts are discarded
+	var attempts, commits, rollbacks int
+	b.SetRetryPolicy(NewRetryPolicy(100))
+	if err := b.Do(context.Background(), SQLITE_TXN_DEFAULT, func(txn SQTransaction) error {
+		attempts++
+		txn.OnCommit(func() { commits++ })
+		txn.OnRollback(func() { rollbacks++ })
+		_, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)"))
+		return err
+	}); err != nil {
+		t.Error(err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if attempts < 2 {
+		t.Error("Expected the transaction to be retried, attempts=", attempts)
+	}
+	if commits != 1 || rollbacks != 0 {
+		t.Error("Unexpected callbacks, commits=", commits, " rollbacks=", rollbacks)
+	}
+
+	// When the final attempt fails, its rollback functions are called once
+	attempts, commits, rollbacks = 0, 0, 0
+	b.SetRetryPolicy(NewRetryPolicy(3))
+	if err := b.Do(context.Background(), SQLITE_TXN_DEFAULT, func(txn SQTransaction) error {
+		attempts++
+		txn.OnRollback(func() { rollbacks++ })
+		return a.Do(context.Background(), 0, func(SQTransaction) error {
+			_, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)"))
+			return err
+		})
+	}); !IsRetryable(err) {
+		t.Error("Expected retryable error, got", err)
+	}
+	if attempts != 3 || rollbacks != 1 {
+		t.Error("Unexpected callbacks, attempts=", attempts, " rollbacks=", rollbacks)
+	}
+}
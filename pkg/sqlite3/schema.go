@@ -1,11 +1,10 @@
 package sqlite3
 
 import (
-
-	// Namespace imports
-
 	"strconv"
+	"strings"
 
+	// Namespace imports
 	. "github.com/mutablelogic/go-sqlite"
 	. "github.com/mutablelogic/go-sqlite/pkg/lang"
 )
@@ -60,27 +59,68 @@ func (c *Conn) Count(schema, table string) int64 {
 	return result
 }
 
-// ColumnsForTable returns the columns in a table
+// ColumnsForTable returns the columns in a table, including any hidden
+// and generated columns
 func (c *Conn) ColumnsForTable(schema, table string) []SQColumn {
 	if schema == "" {
 		return c.ColumnsForTable(DefaultSchema, table)
 	}
 	result := []SQColumn{}
-	if err := c.Exec(Q("PRAGMA ", N(schema), ".table_info(", N(table), ")"), func(row, k []string) bool {
-		// k is "cid" "name" "type" "notnull" "dflt_value" "pk"
+	stored := map[int]bool{}
+	if err := c.Exec(Q("PRAGMA ", N(schema), ".table_xinfo(", N(table), ")"), func(row, k []string) bool {
+		// k is "cid" "name" "type" "notnull" "dflt_value" "pk" "hidden"
 		col := C(row[1]).WithType(row[2])
 		if stringToBool(row[3]) {
 			col = col.NotNull()
 		}
+		if row[4] != "" {
+			col = col.WithDefault(Q(row[4]))
+		}
 		if stringToBool(row[5]) {
 			col = col.WithPrimary()
 		}
-		// TODO: Add default value, auto increment
+		switch row[6] {
+		case "1":
+			col = col.WithHidden()
+		case "2", "3":
+			stored[len(result)] = row[6] == "3"
+		}
 		result = append(result, col)
 		return false
 	}); err != nil {
 		return nil
 	}
+
+	// Set generated columns with the expressions from the CREATE statement,
+	// which cannot be read while the pragma is executing
+	if len(stored) > 0 {
+		ddl := ddlParseTable(c.CreateStatement(schema, table))
+		for i, stored := range stored {
+			var expr SQExpr
+			if ddl != nil && ddl.generated[result[i].Name()] != "" {
+				expr = Q(ddl.generated[result[i].Name()])
+			}
+			result[i] = result[i].WithGenerated(expr, stored)
+		}
+	}
+
+	// Set collation sequence and autoincrement from the column metadata, which
+	// is not available for views
+	for i, col := range result {
+		meta, err := c.ConnEx.ColumnMetadata(schema, table, col.Name())
+		if err != nil {
+			continue
+		}
+		if meta.AutoIncrement {
+			col = col.WithAutoIncrement()
+		}
+		if meta.Collation != "" && !strings.EqualFold(meta.Collation, defaultCollation) {
+			col = col.WithCollation(meta.Collation)
+		}
+		result[i] = col
+	}
+
+	// Return success
 	return result
 }
 
@@ -145,14 +185,187 @@ func (c *Conn) Views(schema string) []string {
 	return c.objectsInSchema(schema, "view")
 }
 
+// Triggers returns a list of trigger names in a schema
+func (c *Conn) Triggers(schema string) []string {
+	if schema == "" {
+		return c.Triggers(DefaultSchema)
+	}
+	return c.objectsInSchema(schema, "trigger")
+}
+
+// TriggersForTable returns the triggers associated with a table
+func (c *Conn) TriggersForTable(schema, table string) []SQTrigger {
+	if table == "" {
+		return nil
+	} else if schema == "" {
+		return c.TriggersForTable(DefaultSchema, table)
+	}
+	result := []SQTrigger{}
+	if err := c.Exec(Q("SELECT sql FROM ", masterTable(schema), " WHERE type='trigger' AND tbl_name=", V(table)), func(row, _ []string) bool {
+		if trigger := ddlParseTrigger(schema, row[0]); trigger != nil {
+			result = append(result, trigger)
+		}
+		return false
+	}); err != nil {
+		return nil
+	}
+	return result
+}
+
+// ForeignKeysForTable returns the foreign key constraints for a table, in
+// the order they were declared
+func (c *Conn) ForeignKeysForTable(schema, table string) []SQForeignKey {
+	if table == "" {
+		return nil
+	} else if schema == "" {
+		return c.ForeignKeysForTable(DefaultSchema, table)
+	}
+	type fkey struct {
+		parent             string
+		keys, columns      []string
+		ondelete, onupdate string
+	}
+	keys := []*fkey{}
+	if err := c.Exec(Q("PRAGMA ", N(schema), ".foreign_key_list(", N(table), ")"), func(row, _ []string) bool {
+		// columns are "id" "seq" "table" "from" "to" "on_update" "on_delete" "match"
+		if row[1] == "0" {
+			keys = append(keys, &fkey{parent: row[2], onupdate: row[5], ondelete: row[6]})
+		}
+		key := keys[len(keys)-1]
+		key.keys = append(key.keys, row[3])
+		if row[4] != "" {
+			key.columns = append(key.columns, row[4])
+		}
+		return false
+	}); err != nil {
+		return nil
+	}
+
+	// Keys are returned in reverse order of declaration
+	result := make([]SQForeignKey, 0, len(keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := keys[i]
+		fk := N(key.parent).ForeignKey(key.columns...).WithKeys(key.keys...)
+		if key.ondelete != "" && key.ondelete != defaultForeignKeyAction {
+			fk = fk.OnDelete(key.ondelete)
+		}
+		if key.onupdate != "" && key.onupdate != defaultForeignKeyAction {
+			fk = fk.OnUpdate(key.onupdate)
+		}
+		result = append(result, fk)
+	}
+	return result
+}
+
+// TableDefinition returns the definition of a table, including unique,
+// foreign key and check constraints and table options. Returns nil if the
+// table does not exist or is a virtual table.
+func (c *Conn) TableDefinition(schema, table string) SQTable {
+	if table == "" {
+		return nil
+	} else if schema == "" {
+		return c.TableDefinition(DefaultSchema, table)
+	}
+
+	// Parse the CREATE statement, which is nil for virtual tables
+	ddl := ddlParseTable(c.CreateStatement(schema, table))
+	if ddl == nil {
+		return nil
+	}
+	columns := c.ColumnsForTable(schema, table)
+	if len(columns) == 0 {
+		return nil
+	}
+
+	// Set columns and options
+	result := N(table).WithSchema(schema).CreateTable(columns...)
+	if schema == tempSchema {
+		result = result.WithTemporary()
+	}
+	if ddl.withoutrowid {
+		result = result.WithoutRowID()
+	}
+	if ddl.strict {
+		result = result.WithStrict()
+	}
+
+	// Set unique constraints, which are indexes created automatically
+	if err := c.ExecEx(Q("PRAGMA ", N(schema), ".index_list(", N(table), ")").Query(), func(row, _ []string) bool {
+		// columns are is "seq" "name" "unique" "origin" "partial"
+		if row[3] == "u" {
+			if names := c.ColumnsForIndex(schema, row[1]); len(names) > 0 {
+				result = result.WithUnique(names...)
+			}
+		}
+		return false
+	}); err != nil {
+		return nil
+	}
+
+	// Set foreign key and check constraints
+	for _, fk := range c.ForeignKeysForTable(schema, table) {
+		result = result.WithForeignKey(fk)
+	}
+	for _, check := range ddl.checks {
+		result = result.WithCheck(Q(check))
+	}
+
+	// Return success
+	return result
+}
+
+// CreateStatement returns the original CREATE statement for a table, index,
+// view or trigger
+func (c *Conn) CreateStatement(schema, name string) string {
+	if name == "" {
+		return ""
+	} else if schema == "" {
+		return c.CreateStatement(DefaultSchema, name)
+	}
+	var result string
+	if err := c.Exec(Q("SELECT sql FROM ", masterTable(schema), " WHERE name=", V(name)), func(row, _ []string) bool {
+		result = row[0]
+		return false
+	}); err != nil {
+		return ""
+	}
+	return result
+}
+
 // Modules returns a list of modules in a schema. If an argument is
 // provided, then only modules with those name prefixes are returned.
 func (c *Conn) Modules(prefix ...string) []string {
-	// Get the names, return
+	return c.list(Q("PRAGMA module_list"), prefix)
+}
+
+// Functions returns a list of scalar, aggregate and window functions. If an
+// argument is provided, then only functions with those name prefixes are
+// returned.
+func (c *Conn) Functions(prefix ...string) []string {
+	return c.list(Q("SELECT DISTINCT name FROM pragma_function_list"), prefix)
+}
+
+// Collations returns a list of collating sequences. If an argument is
+// provided, then only collations with those name prefixes are returned.
+func (c *Conn) Collations(prefix ...string) []string {
+	return c.list(Q("PRAGMA collation_list"), prefix, 1)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// list returns the values in a column of a statement's results which match
+// any of the prefixes, or all values if there are no prefixes. The first
+// column is used unless a column index is provided.
+func (c *Conn) list(st SQStatement, prefix []string, column ...int) []string {
+	i := 0
+	if len(column) > 0 {
+		i = column[0]
+	}
 	result := []string{}
-	if err := c.Exec(Q("PRAGMA module_list"), func(row, _ []string) bool {
-		if module := row[0]; len(prefix) == 0 || inList(prefix, module, true) {
-			result = append(result, module)
+	if err := c.Exec(st, func(row, _ []string) bool {
+		if name := row[i]; len(prefix) == 0 || inList(prefix, name, true) {
+			result = append(result, name)
 		}
 		return false
 	}); err != nil {
@@ -161,19 +374,19 @@ func (c *Conn) Modules(prefix ...string) []string {
 	return result
 }
 
-////////////////////////////////////////////////////////////////////////////////
-// PRIVATE METHODS
-
-func (c *Conn) objectsInSchema(schema, t string) []string {
-	// Set the schema
-	tableName := N("sqlite_master").WithSchema(schema)
+// masterTable returns the table which contains the schema
+func masterTable(schema string) SQSource {
 	if schema == tempSchema {
-		tableName = N("sqlite_temp_master").WithSchema(schema)
+		return N("sqlite_temp_master").WithSchema(schema)
+	} else {
+		return N("sqlite_master").WithSchema(schema)
 	}
+}
 
+func (c *Conn) objectsInSchema(schema, t string) []string {
 	// Get the names, return
 	var result []string
-	if err := c.Exec(Q("SELECT name FROM ", tableName, " WHERE type=", V(t), " AND name NOT LIKE 'sqlite_%%'"), func(row, _ []string) bool {
+	if err := c.Exec(Q("SELECT name FROM ", masterTable(schema), " WHERE type=", V(t), " AND name NOT LIKE 'sqlite_%%'"), func(row, _ []string) bool {
 		result = append(result, row[0])
 		return false
 	}); err != nil {
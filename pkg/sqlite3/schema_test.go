@@ -1,8 +1,10 @@
 package sqlite3_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,7 +15,7 @@ import (
 
 func Test_Schema_001(t *testing.T) {
 	errs, cancel := handleErrors(t)
-	cfg := NewConfig().WithTrace(func(sql string, d time.Duration) {
+	cfg := NewConfig().WithTrace(func(_ *Conn, sql string, d time.Duration) {
 		if d > 0 {
 			t.Log(sql, "=>", d)
 		}
@@ -54,7 +56,7 @@ func Test_Schema_002(t *testing.T) {
 	defer os.RemoveAll(tmpdir)
 
 	// Make configuration
-	cfg := NewConfig().WithTrace(func(sql string, d time.Duration) {
+	cfg := NewConfig().WithTrace(func(_ *Conn, sql string, d time.Duration) {
 		if d > 0 {
 			t.Log(sql, "=>", d)
 		}
@@ -100,7 +102,7 @@ func Test_Schema_003(t *testing.T) {
 	defer os.RemoveAll(tmpdir)
 
 	// Make configuration
-	cfg := NewConfig().WithTrace(func(sql string, d time.Duration) {
+	cfg := NewConfig().WithTrace(func(_ *Conn, sql string, d time.Duration) {
 		if d > 0 {
 			t.Log(sql, "=>", d)
 		}
@@ -174,7 +176,7 @@ func Test_Schema_004(t *testing.T) {
 	defer os.RemoveAll(tmpdir)
 
 	// Make configuration
-	cfg := NewConfig().WithTrace(func(sql string, d time.Duration) {
+	cfg := NewConfig().WithTrace(func(_ *Conn, sql string, d time.Duration) {
 		if d > 0 {
 			t.Log(sql, "=>", d)
 		}
@@ -221,7 +223,7 @@ func Test_Schema_006(t *testing.T) {
 	errs, cancel := handleErrors(t)
 
 	// Make configuration
-	cfg := NewConfig().WithTrace(func(sql string, d time.Duration) {
+	cfg := NewConfig().WithTrace(func(_ *Conn, sql string, d time.Duration) {
 		if d > 0 {
 			t.Log(sql, "=>", d)
 		}
@@ -273,7 +275,7 @@ func Test_Schema_007(t *testing.T) {
 	defer os.RemoveAll(tmpdir)
 
 	// Make configuration
-	cfg := NewConfig().WithTrace(func(sql string, d time.Duration) {
+	cfg := NewConfig().WithTrace(func(_ *Conn, sql string, d time.Duration) {
 		if d > 0 {
 			t.Log(sql, "=>", d)
 		}
@@ -319,3 +321,130 @@ func Test_Schema_007(t *testing.T) {
 		t.Logf("indexes: %q", indexes)
 	}
 }
+
+func Test_Schema_008(t *testing.T) {
+	// Create error channel
+	errs, cancel := handleErrors(t)
+
+	// Create pool
+	pool, err := OpenPool(NewConfig(), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	defer cancel()
+
+	// Get connection
+	conn := pool.Get()
+	defer pool.Put(conn)
+
+	// Create a table
+	if err := conn.Exec(Q(`CREATE TABLE table_a (
+		a INTEGER PRIMARY KEY AUTOINCREMENT,
+		b TEXT NOT NULL DEFAULT 'b' COLLATE NOCASE,
+		c VARCHAR(20) DEFAULT CURRENT_TIMESTAMP,
+		d FLOAT GENERATED ALWAYS AS (a * 2) STORED,
+		e BLOB GENERATED ALWAYS AS (b) VIRTUAL
+	)`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Obtain the columns
+	columns := conn.ColumnsForTable("main", "table_a")
+	if len(columns) != 5 {
+		t.Fatalf("Unexpected return from columns: %q", columns)
+	}
+	if col := columns[0]; !col.AutoIncrement() || col.Primary() == "" || col.Affinity() != "INTEGER" {
+		t.Error("Unexpected column", col)
+	}
+	if col := columns[1]; col.Nullable() || col.Collation() != "NOCASE" || fmt.Sprint(col.Default()) != "'b'" || col.Affinity() != "TEXT" {
+		t.Error("Unexpected column", col)
+	}
+	if col := columns[2]; !col.Nullable() || col.Collation() != "" || fmt.Sprint(col.Default()) != "CURRENT_TIMESTAMP" || col.Affinity() != "TEXT" {
+		t.Error("Unexpected column", col)
+	}
+	if col := columns[3]; col.Generated() != "STORED" || col.Affinity() != "REAL" || fmt.Sprint(col) != "d FLOAT GENERATED ALWAYS AS (a * 2) STORED" {
+		t.Error("Unexpected column", col)
+	}
+	if col := columns[4]; col.Generated() != "VIRTUAL" || col.Affinity() != "BLOB" || fmt.Sprint(col) != "e BLOB GENERATED ALWAYS AS (b) VIRTUAL" {
+		t.Error("Unexpected column", col)
+	}
+}
+
+func Test_Schema_009(t *testing.T) {
+	// Create error channel
+	errs, cancel := handleErrors(t)
+
+	// Create pool
+	pool, err := OpenPool(NewConfig(), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	defer cancel()
+
+	// Get connection
+	conn := pool.Get()
+	defer pool.Put(conn)
+
+	// Create tables and a trigger
+	if err := conn.Exec(Q(`
+		CREATE TABLE parent (a INTEGER PRIMARY KEY, b TEXT NOT NULL, UNIQUE (b)) STRICT;
+		CREATE TABLE child (
+			c TEXT NOT NULL PRIMARY KEY CHECK (c <> ''),
+			d INTEGER REFERENCES parent (a) ON DELETE CASCADE,
+			e TEXT GENERATED ALWAYS AS (upper(c)) VIRTUAL,
+			f TEXT, g TEXT,
+			FOREIGN KEY (f, g) REFERENCES other /* comment, (x) */ ON UPDATE SET NULL,
+			CONSTRAINT "check;" CHECK (length(f) < 10 AND g IN ('a', 'b)'))
+		) WITHOUT ROWID;
+		CREATE TRIGGER child_update AFTER UPDATE OF c, d ON child FOR EACH ROW WHEN new.d > 0 BEGIN
+			UPDATE parent SET b = 'x;y' WHERE a = new.d;
+			DELETE FROM parent WHERE a = old.d;
+		END;
+	`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Triggers
+	if triggers := conn.Triggers("main"); len(triggers) != 1 || triggers[0] != "child_update" {
+		t.Errorf("Unexpected return from triggers: %q", triggers)
+	}
+	if triggers := conn.TriggersForTable("main", "child"); len(triggers) != 1 {
+		t.Errorf("Unexpected return from triggers: %q", triggers)
+	} else if q := triggers[0].Query(); q != `CREATE TRIGGER main.child_update AFTER UPDATE OF c,d ON child WHEN new.d > 0 BEGIN UPDATE parent SET b = 'x;y' WHERE a = new.d; DELETE FROM parent WHERE a = old.d; END` {
+		t.Errorf("Unexpected trigger: %q", q)
+	}
+
+	// Foreign keys
+	if fks := conn.ForeignKeysForTable("main", "child"); len(fks) != 2 {
+		t.Errorf("Unexpected return from foreign keys: %q", fks)
+	} else {
+		if fk := fmt.Sprint(fks[0]); fk != `FOREIGN KEY (d) REFERENCES parent (a) ON DELETE CASCADE` {
+			t.Errorf("Unexpected foreign key: %q", fk)
+		}
+		if fk := fmt.Sprint(fks[1]); fk != `FOREIGN KEY (f,g) REFERENCES other ON UPDATE SET NULL` {
+			t.Errorf("Unexpected foreign key: %q", fk)
+		}
+	}
+
+	// Table definitions
+	if table := conn.TableDefinition("main", "parent"); table == nil {
+		t.Error("Unexpected nil table definition")
+	} else if q := table.Query(); q != `CREATE TABLE main.parent (a INTEGER NOT NULL PRIMARY KEY,b TEXT NOT NULL,UNIQUE (b)) STRICT` {
+		t.Errorf("Unexpected table definition: %q", q)
+	}
+	if table := conn.TableDefinition("main", "child"); table == nil {
+		t.Error("Unexpected nil table definition")
+	} else if q := table.Query(); q != `CREATE TABLE main.child (c TEXT NOT NULL PRIMARY KEY,d INTEGER,e TEXT GENERATED ALWAYS AS (upper(c)) VIRTUAL,f TEXT,g TEXT,FOREIGN KEY (d) REFERENCES parent (a) ON DELETE CASCADE,FOREIGN KEY (f,g) REFERENCES other ON UPDATE SET NULL,CHECK (c <> ''),CHECK (length(f) < 10 AND g IN ('a', 'b)'))) WITHOUT ROWID` {
+		t.Errorf("Unexpected table definition: %q", q)
+	}
+	if table := conn.TableDefinition("main", "missing"); table != nil {
+		t.Error("Expected nil table definition")
+	}
+
+	// Original statement
+	if sql := conn.CreateStatement("main", "parent"); !strings.HasPrefix(sql, "CREATE TABLE parent") {
+		t.Errorf("Unexpected statement: %q", sql)
+	}
+}
@@ -13,10 +13,13 @@ import (
 
 const (
 	// DefaultFlags are the default flags for a new database connection
-	DefaultFlags  = SQFlag(sqlite3.SQLITE_OPEN_CREATE | sqlite3.SQLITE_OPEN_READWRITE)
-	DefaultSchema = sqlite3.DefaultSchema
-	defaultMemory = sqlite3.DefaultMemory
-	tempSchema    = "temp"
+	DefaultFlags            = SQFlag(sqlite3.SQLITE_OPEN_CREATE | sqlite3.SQLITE_OPEN_READWRITE)
+	DefaultSchema           = sqlite3.DefaultSchema
+	defaultMemory           = sqlite3.DefaultMemory
+	tempSchema              = "temp"
+	defaultCollation        = "BINARY"
+	defaultForeignKeyAction = "NO ACTION"
+	savepointPrefix         = "savepoint"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
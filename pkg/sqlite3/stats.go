@@ -0,0 +1,130 @@
+package sqlite3
+
+import (
+	"fmt"
+	"time"
+
+	// Modules
+	sqlite3 "github.com/mutablelogic/go-sqlite/sys/sqlite3"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// PoolStats contains statistics about a pool and the connections in it
+type PoolStats struct {
+	Open         int              // Number of open connections, including the writer in WAL mode
+	Idle         int              // Number of idle connections
+	InUse        int              // Number of connections checked out
+	Checkouts    int64            // Total number of connections checked out
+	WaitCount    int64            // Total number of times a caller waited for a connection
+	WaitDuration time.Duration    // Total time callers waited for a connection
+	Closed       map[string]int64 // Number of connections closed, for each reason
+
+	// Prepared statement cache, summed across connections
+	CacheHits      uint64
+	CacheMisses    uint64
+	CacheEvictions uint64
+
+	// Memory used by sqlite, and memory used by idle connections in bytes
+	MemoryUsed      int64
+	MemoryHighwater int64
+	PageCacheUsed   int64
+	SchemaUsed      int64
+	StatementUsed   int64
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+// Reasons connections are closed
+const (
+	ClosedPool           = "pool"
+	ClosedMaxConnections = "max_connections"
+	ClosedMaxIdle        = "max_idle"
+	ClosedIdleTimeout    = "idle_timeout"
+	ClosedMaxLifetime    = "max_lifetime"
+	ClosedPing           = "ping"
+	ClosedAttach         = "attach"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (s PoolStats) String() string {
+	str := "<stats"
+	str += fmt.Sprint(" open=", s.Open)
+	str += fmt.Sprint(" idle=", s.Idle)
+	str += fmt.Sprint(" in_use=", s.InUse)
+	str += fmt.Sprint(" checkouts=", s.Checkouts)
+	if s.WaitCount > 0 {
+		str += fmt.Sprint(" wait_count=", s.WaitCount)
+		str += fmt.Sprint(" wait_duration=", s.WaitDuration)
+	}
+	for reason, n := range s.Closed {
+		str += fmt.Sprintf(" closed_%s=%d", reason, n)
+	}
+	str += fmt.Sprint(" cache_hits=", s.CacheHits)
+	str += fmt.Sprint(" cache_misses=", s.CacheMisses)
+	str += fmt.Sprint(" cache_evictions=", s.CacheEvictions)
+	str += fmt.Sprint(" memory_used=", s.MemoryUsed)
+	return str + ">"
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Stats returns statistics about the pool and the connections in it
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := PoolStats{
+		Open:         len(p.conns),
+		Idle:         len(p.free),
+		InUse:        p.Cur(),
+		Checkouts:    p.checkouts,
+		WaitCount:    p.waits,
+		WaitDuration: p.wait,
+		Closed:       make(map[string]int64, len(p.closed)),
+	}
+	for reason, n := range p.closed {
+		stats.Closed[reason] = n
+	}
+
+	// Sum cache statistics across connections, which are read atomically
+	conns := make([]*Conn, 0, len(p.conns)+1)
+	for conn := range p.conns {
+		conns = append(conns, conn)
+	}
+	if p.writer != nil {
+		conns = append(conns, p.writer)
+		stats.Open++
+	}
+	for _, conn := range conns {
+		hits, misses, evictions := conn.CacheStats()
+		stats.CacheHits += hits
+		stats.CacheMisses += misses
+		stats.CacheEvictions += evictions
+	}
+
+	// Sum memory statistics across idle connections, as connections which
+	// are checked out may be in use
+	for _, conn := range p.free {
+		if cur, _, err := conn.GetStatus(sqlite3.SQLITE_DBSTATUS_CACHE_USED); err == nil {
+			stats.PageCacheUsed += int64(cur)
+		}
+		if cur, _, err := conn.GetStatus(sqlite3.SQLITE_DBSTATUS_SCHEMA_USED); err == nil {
+			stats.SchemaUsed += int64(cur)
+		}
+		if cur, _, err := conn.GetStatus(sqlite3.SQLITE_DBSTATUS_STMT_USED); err == nil {
+			stats.StatementUsed += int64(cur)
+		}
+	}
+
+	// Memory used by sqlite
+	stats.MemoryUsed, stats.MemoryHighwater = sqlite3.GetMemoryUsed()
+
+	// Return statistics
+	return stats
+}
@@ -0,0 +1,57 @@
+package sqlite3_test
+
+import (
+	"context"
+	"testing"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+func Test_Stats_001(t *testing.T) {
+	errs, cancel := handleErrors(t)
+	defer cancel()
+	pool, err := OpenPool(NewConfig().WithMaxIdle(1), errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Check out two connections and run the same query twice on one
+	a, b := pool.Get(), pool.Get()
+	if a == nil || b == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	for i := 0; i < 2; i++ {
+		if err := a.Do(context.Background(), 0, func(txn SQTransaction) error {
+			_, err := txn.Query(Q("SELECT 1"))
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := pool.Stats()
+	t.Log(stats)
+	if stats.Open != 2 || stats.InUse != 2 || stats.Idle != 0 || stats.Checkouts != 2 {
+		t.Error("Unexpected stats", stats)
+	}
+	if stats.CacheHits != 1 || stats.CacheMisses != 1 {
+		t.Error("Unexpected cache stats", stats)
+	}
+	if stats.MemoryUsed == 0 || stats.StatementUsed != 0 {
+		t.Error("Unexpected memory stats", stats)
+	}
+
+	// Return connections, one is closed as it exceeds max idle
+	pool.Put(a)
+	pool.Put(b)
+	stats = pool.Stats()
+	if stats.Open != 1 || stats.InUse != 0 || stats.Idle != 1 || stats.Closed[ClosedMaxIdle] != 1 {
+		t.Error("Unexpected stats", stats)
+	}
+	if stats.StatementUsed == 0 {
+		t.Error("Unexpected memory stats", stats)
+	}
+}
@@ -0,0 +1,103 @@
+package sqlite3
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// regexpcache caches compiled regular expressions
+type regexpcache struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	// Maximum number of compiled regular expressions to cache
+	regexpCacheSize = 100
+)
+
+var (
+	regexps = &regexpcache{m: make(map[string]*regexp.Regexp)}
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Stdlib returns the standard library of extensions, which can be
+// registered on each connection with PoolConfig.WithExtensions:
+//
+//	regexp(pattern, text) returns 1 if text matches the pattern, which implements the REGEXP operator
+//	regexp_substr(text, pattern) returns the first match of the pattern in text, or NULL
+//	regexp_replace(text, pattern, replacement) replaces matches of the pattern in text
+//
+// Patterns use the Go regular expression syntax. The functions return NULL
+// when any argument is NULL.
+func Stdlib() []Extension {
+	return []Extension{
+		Function("regexp", 2, true, stdRegexp),
+		Function("regexp_substr", 2, true, stdRegexpSubstr),
+		Function("regexp_replace", 3, true, stdRegexpReplace),
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func stdRegexp(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	} else if re, err := regexps.compile(args[0]); err != nil {
+		return nil, err
+	} else {
+		return re.MatchString(fmt.Sprint(args[1])), nil
+	}
+}
+
+func stdRegexpSubstr(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	} else if re, err := regexps.compile(args[1]); err != nil {
+		return nil, err
+	} else if match := re.FindStringIndex(fmt.Sprint(args[0])); match == nil {
+		return nil, nil
+	} else {
+		return fmt.Sprint(args[0])[match[0]:match[1]], nil
+	}
+}
+
+func stdRegexpReplace(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil || args[2] == nil {
+		return nil, nil
+	} else if re, err := regexps.compile(args[1]); err != nil {
+		return nil, err
+	} else {
+		return re.ReplaceAllString(fmt.Sprint(args[0]), fmt.Sprint(args[2])), nil
+	}
+}
+
+// compile returns a compiled regular expression, and caches it. The cache
+// is emptied when it is full.
+func (c *regexpcache) compile(pattern interface{}) (*regexp.Regexp, error) {
+	expr := fmt.Sprint(pattern)
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	if re, exists := c.m[expr]; exists {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.m) >= regexpCacheSize {
+		c.m = make(map[string]*regexp.Regexp)
+	}
+	c.m[expr] = re
+	return re, nil
+}
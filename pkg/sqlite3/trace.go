@@ -1,6 +1,10 @@
 package sqlite3
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -8,29 +12,311 @@ import (
 	"github.com/mutablelogic/go-sqlite/sys/sqlite3"
 )
 
+////////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Tracer receives a span for each statement executed on a connection. The
+// span passed to EndSpan is the same span passed to StartSpan, with the
+// duration, rows, changes and any error populated
+type Tracer interface {
+	// StartSpan is called when a statement starts executing
+	StartSpan(*Span)
+
+	// EndSpan is called when a statement has completed
+	EndSpan(*Span)
+}
+
+// Span represents the execution of a single statement
+type Span struct {
+	Conn     int64         // Connection counter
+	Txn      int64         // Transaction counter, or zero if outside a transaction
+	SQL      string        // Unexpanded SQL
+	Expanded string        // SQL with bound parameters expanded
+	Start    time.Time     // Time the statement started executing
+	Duration time.Duration // Time taken to execute the statement
+	Rows     int           // Number of rows produced
+	Changes  int           // Number of rows inserted, updated or deleted
+	Err      error         // Any error which occurred
+
+	total int // Total changes on the connection when the span started
+}
+
+// tracers passes spans to each tracer in turn
+type tracers []Tracer
+
+// tracefunc calls a trace function with the SQL and duration when each
+// statement has completed
+type tracefunc struct {
+	*Conn
+	fn TraceFunc
+}
+
+// jsontracer writes a JSON line for each completed span
+type jsontracer struct {
+	sync.Mutex
+	*json.Encoder
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	traceFlags = sqlite3.SQLITE_TRACE_STMT | sqlite3.SQLITE_TRACE_PROFILE | sqlite3.SQLITE_TRACE_ROW | sqlite3.SQLITE_TRACE_CLOSE
+)
+
 ////////////////////////////////////////////////////////////////////////////////
 // LIFECYCLE
 
+// NewJSONTracer returns a tracer which writes each completed span as a line
+// of JSON to w
+func NewJSONTracer(w io.Writer) Tracer {
+	return &jsontracer{Encoder: json.NewEncoder(w)}
+}
+
 // SetTraceHook sets a function to receive executed SQL statements, with
 // the time it took to execute them. The callback is provided with the
 // SQL statement. If the second argument is less than zero, the callback is preparing
 // a statement for execution. If the second argument is non-zero, the
 // callback is invoked when the statement is completed.
 func (c *Conn) SetTraceHook(fn TraceFunc) {
-	if fn == nil {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+	c.tracefn = fn
+	c.setTrace()
+}
+
+// SetTracer sets a tracer to receive a span for each statement executed,
+// or removes the tracer when nil
+func (c *Conn) SetTracer(tracer Tracer) {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+	c.tracer = tracer
+	c.setTrace()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (s *Span) String() string {
+	str := "<span"
+	str += fmt.Sprint(" conn=", s.Conn)
+	if s.Txn != 0 {
+		str += fmt.Sprint(" txn=", s.Txn)
+	}
+	str += fmt.Sprintf(" sql=%q", s.SQL)
+	if s.Expanded != "" && s.Expanded != s.SQL {
+		str += fmt.Sprintf(" expanded_sql=%q", s.Expanded)
+	}
+	if s.Duration > 0 {
+		str += fmt.Sprint(" duration=", s.Duration)
+	}
+	if s.Rows > 0 {
+		str += fmt.Sprint(" rows=", s.Rows)
+	}
+	if s.Changes > 0 {
+		str += fmt.Sprint(" changes=", s.Changes)
+	}
+	if s.Err != nil {
+		str += fmt.Sprintf(" err=%q", s.Err.Error())
+	}
+	return str + ">"
+}
+
+// MarshalJSON returns the span as a JSON object
+func (s *Span) MarshalJSON() ([]byte, error) {
+	type span struct {
+		Conn     int64     `json:"conn"`
+		Txn      int64     `json:"txn,omitempty"`
+		SQL      string    `json:"sql"`
+		Expanded string    `json:"expanded_sql,omitempty"`
+		Start    time.Time `json:"start"`
+		Duration int64     `json:"duration_ns"`
+		Rows     int       `json:"rows"`
+		Changes  int       `json:"changes"`
+		Err      string    `json:"error,omitempty"`
+	}
+	v := span{s.Conn, s.Txn, s.SQL, s.Expanded, s.Start, s.Duration.Nanoseconds(), s.Rows, s.Changes, ""}
+	if s.Err != nil {
+		v.Err = s.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - TRACERS
+
+func (t tracers) StartSpan(s *Span) {
+	for _, tracer := range t {
+		tracer.StartSpan(s)
+	}
+}
+
+func (t tracers) EndSpan(s *Span) {
+	for _, tracer := range t {
+		tracer.EndSpan(s)
+	}
+}
+
+func (t tracefunc) StartSpan(*Span) {
+	// The trace function is only called when the statement has completed
+}
+
+func (t tracefunc) EndSpan(s *Span) {
+	t.fn(t.Conn, s.SQL, s.Duration)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS - JSON TRACER
+
+func (t *jsontracer) StartSpan(*Span) {
+	// Spans are only written when completed
+}
+
+func (t *jsontracer) EndSpan(s *Span) {
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+	t.Encode(s)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// setTrace sets or removes the trace hook depending on whether a trace
+// function or tracer is set
+func (c *Conn) setTrace() {
+	if c.tracefn == nil && c.tracer == nil {
 		c.ConnEx.SetTraceHook(nil, 0)
-	} else {
-		c.ConnEx.SetTraceHook(func(t sqlite3.TraceType, a, b unsafe.Pointer) int {
-			switch t {
-			case sqlite3.SQLITE_TRACE_STMT:
-				s := (*sqlite3.Statement)(a)
-				fn(c, s.SQL(), -1)
-			case sqlite3.SQLITE_TRACE_PROFILE:
-				s := (*sqlite3.Statement)(a)
-				ns := time.Duration(time.Duration(*(*int64)(b)) * time.Nanosecond)
-				fn(c, s.ExpandedSQL(), ns)
-			}
-			return 0
-		}, sqlite3.SQLITE_TRACE_PROFILE|sqlite3.SQLITE_TRACE_STMT)
+		c.spans, c.pending = nil, nil
+		return
+	}
+	if c.spans == nil {
+		c.spans = make(map[*sqlite3.Statement]*Span)
+	}
+	c.ConnEx.SetTraceHook(func(t sqlite3.TraceType, a, b unsafe.Pointer) int {
+		switch t {
+		case sqlite3.SQLITE_TRACE_STMT:
+			c.traceStart((*sqlite3.Statement)(a))
+		case sqlite3.SQLITE_TRACE_ROW:
+			c.traceRow((*sqlite3.Statement)(a))
+		case sqlite3.SQLITE_TRACE_PROFILE:
+			c.traceEnd((*sqlite3.Statement)(a), time.Duration(*(*int64)(b))*time.Nanosecond)
+		case sqlite3.SQLITE_TRACE_CLOSE:
+			c.traceClose()
+		}
+		return 0
+	}, traceFlags)
+}
+
+// traceStart is called when a statement starts executing. It is also called
+// for each trigger invoked by the statement, which is ignored. Trace functions
+// and tracers are called once the trace lock is released.
+func (c *Conn) traceStart(st *sqlite3.Statement) {
+	c.tmu.Lock()
+	if _, exists := c.spans[st]; exists {
+		c.tmu.Unlock()
+		return
+	}
+	spans := c.flush(nil)
+	span := &Span{
+		Conn:  c.counter,
+		Txn:   c.txn,
+		SQL:   st.SQL(),
+		Start: time.Now(),
+		total: c.ConnEx.TotalChanges(),
+	}
+	c.spans[st] = span
+	fn, tracer := c.tracefn, c.tracer
+	c.tmu.Unlock()
+
+	// End completed spans, then start the new span
+	endSpans(tracer, spans)
+	if fn != nil {
+		fn(c, span.SQL, -1)
+	}
+	if tracer != nil {
+		tracer.StartSpan(span)
+	}
+}
+
+// traceRow is called for each row produced by a statement
+func (c *Conn) traceRow(st *sqlite3.Statement) {
+	c.tmu.Lock()
+	defer c.tmu.Unlock()
+	if span, exists := c.spans[st]; exists {
+		span.Rows++
+	}
+}
+
+// traceEnd is called when a statement has completed. The span is not ended
+// until any error from the statement is known, which is when the next
+// statement starts or traceFlush is called
+func (c *Conn) traceEnd(st *sqlite3.Statement, d time.Duration) {
+	c.tmu.Lock()
+	span, exists := c.spans[st]
+	if !exists {
+		c.tmu.Unlock()
+		return
+	}
+	delete(c.spans, st)
+
+	// Set the span properties
+	span.Expanded = st.ExpandedSQL()
+	span.Duration = d
+	span.Changes = c.ConnEx.TotalChanges() - span.total
+	c.pending = append(c.pending, span)
+	fn := c.tracefn
+	c.tmu.Unlock()
+
+	// Call the trace function once unlocked
+	if fn != nil {
+		fn(c, span.Expanded, d)
+	}
+}
+
+// traceClose is called when the connection is closed, and ends any spans
+// which have not completed
+func (c *Conn) traceClose() {
+	c.tmu.Lock()
+	for st, span := range c.spans {
+		delete(c.spans, st)
+		span.Duration = time.Since(span.Start)
+		c.pending = append(c.pending, span)
+	}
+	spans, tracer := c.flush(nil), c.tracer
+	c.tmu.Unlock()
+	endSpans(tracer, spans)
+}
+
+// traceFlush ends any completed spans, setting the error on the most
+// recently completed span
+func (c *Conn) traceFlush(err error) {
+	c.tmu.Lock()
+	spans, tracer := c.flush(err), c.tracer
+	c.tmu.Unlock()
+	endSpans(tracer, spans)
+}
+
+// flush returns any completed spans, setting the error on the most recently
+// completed span. The spans are ended with endSpans once the trace lock is
+// released.
+func (c *Conn) flush(err error) []*Span {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	if err != nil {
+		c.pending[len(c.pending)-1].Err = err
+	}
+	spans := c.pending
+	c.pending = nil
+	return spans
+}
+
+// endSpans passes completed spans to a tracer
+func endSpans(tracer Tracer, spans []*Span) {
+	if tracer == nil {
+		return
+	}
+	for _, span := range spans {
+		tracer.EndSpan(span)
 	}
 }
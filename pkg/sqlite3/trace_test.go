@@ -0,0 +1,204 @@
+package sqlite3_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	// Namespace Imports
+	. "github.com/mutablelogic/go-sqlite"
+	. "github.com/mutablelogic/go-sqlite/pkg/lang"
+	. "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+type SpanTracer struct {
+	sync.Mutex
+	spans []*Span
+}
+
+func (t *SpanTracer) StartSpan(span *Span) {
+	// Do nothing
+}
+
+func (t *SpanTracer) EndSpan(span *Span) {
+	t.Lock()
+	defer t.Unlock()
+	t.spans = append(t.spans, span)
+}
+
+func Test_Trace_001(t *testing.T) {
+	tracer := new(SpanTracer)
+	conn, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetTracer(tracer)
+
+	// Create table and insert rows in a transaction
+	if err := conn.Exec(N("test").CreateTable(C("a").WithType("INTEGER").WithPrimary()), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		for i := 1; i <= 3; i++ {
+			if _, err := txn.Query(Q("INSERT INTO test (a) VALUES (?)"), i); err != nil {
+				return err
+			}
+		}
+		r, err := txn.Query(S(N("test")))
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		for row := r.Next(); row != nil; row = r.Next() {
+			t.Log(row)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check spans
+	var txn int64
+	for _, span := range tracer.spans {
+		t.Log(span)
+		switch {
+		case strings.HasPrefix(span.SQL, "BEGIN"):
+			txn = span.Txn
+		case strings.HasPrefix(span.SQL, "INSERT"):
+			if span.Changes != 1 {
+				t.Error("Unexpected changes", span)
+			} else if span.Txn == 0 || span.Txn != txn {
+				t.Error("Unexpected txn", span)
+			} else if span.Expanded == span.SQL {
+				t.Error("Unexpected expanded SQL", span)
+			}
+		case strings.HasPrefix(span.SQL, "SELECT"):
+			if span.Rows != 3 {
+				t.Error("Unexpected rows", span)
+			}
+		case strings.HasPrefix(span.SQL, "CREATE"):
+			if span.Txn != 0 {
+				t.Error("Unexpected txn", span)
+			}
+		}
+	}
+	if txn == 0 {
+		t.Error("Missing BEGIN span")
+	}
+}
+
+func Test_Trace_002(t *testing.T) {
+	var buf bytes.Buffer
+	conn, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetTracer(NewJSONTracer(&buf))
+
+	// Insert a duplicate key, which should report an error
+	if err := conn.Exec(N("test").CreateTable(C("a").WithType("INTEGER").WithPrimary()), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		if _, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)")); err != nil {
+			return err
+		}
+		_, err := txn.Query(Q("INSERT INTO test (a) VALUES (1)"))
+		return err
+	}); err == nil {
+		t.Error("Expected error")
+	}
+
+	// Decode the JSON lines, expect one error
+	var errs int
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var span map[string]interface{}
+		if err := dec.Decode(&span); err != nil {
+			t.Fatal(err)
+		}
+		t.Log(span)
+		if _, exists := span["error"]; exists {
+			errs++
+		}
+	}
+	if errs != 1 {
+		t.Error("Unexpected number of errors", errs)
+	}
+}
+
+func Test_Trace_003(t *testing.T) {
+	var mu sync.Mutex
+	var traces []string
+	pool, err := OpenPool(NewConfig().WithTrace(func(_ *Conn, sql string, d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		if d < 0 {
+			t.Error("Unexpected trace at statement start:", sql)
+		}
+		traces = append(traces, sql)
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn := pool.Get()
+	if conn == nil {
+		t.Fatal("Unexpected nil connection")
+	}
+	defer pool.Put(conn)
+
+	// The pool trace function is called once for each statement
+	if err := conn.Do(context.Background(), 0, func(txn SQTransaction) error {
+		r, err := txn.Query(Q("SELECT ?"), 1)
+		if err != nil {
+			return err
+		}
+		return r.Close()
+	}); err != nil {
+		t.Error(err)
+	}
+	mu.Lock()
+	var selects int
+	for _, sql := range traces {
+		if sql == "SELECT ?" {
+			selects++
+		}
+	}
+	mu.Unlock()
+	if selects != 1 {
+		t.Error("Unexpected traces:", traces)
+	}
+}
+
+func Test_Trace_004(t *testing.T) {
+	tracer := new(SpanTracer)
+	conn, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Trace functions are called without the trace lock, so they can
+	// change tracing on the connection
+	var calls int
+	conn.SetTraceHook(func(c *Conn, sql string, d time.Duration) {
+		calls++
+		c.SetTracer(tracer)
+	})
+	if err := conn.Exec(Q("SELECT 1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Exec(Q("SELECT 2"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls == 0 {
+		t.Error("Expected trace function to be called")
+	}
+}
@@ -1,6 +1,7 @@
 package sqlite3
 
 import (
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -93,3 +94,24 @@ func durationMax(a, b time.Duration) time.Duration {
 	}
 	return b
 }
+
+// samePath returns true if the filename of an attached database refers to
+// the path, where memory databases have an empty filename
+func samePath(filename, path string) bool {
+	if path == defaultMemory {
+		return filename == ""
+	} else if filename == "" {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	if real, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = real
+	}
+	return abs == filename
+}
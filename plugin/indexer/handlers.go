@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"net/http"
-	"reflect"
 	"regexp"
 	"strings"
 	"time"
@@ -57,6 +56,20 @@ type ResultResponse struct {
 	File    FileResponse `json:"file"`
 }
 
+type queryRow struct {
+	Id       int64     `sqlite:"rowid"`
+	Rank     float64   `sqlite:"rank"`
+	Snippet  string    `sqlite:"snippet"`
+	Index    string    `sqlite:"name"`
+	Path     string    `sqlite:"path"`
+	Parent   string    `sqlite:"parent"`
+	Filename string    `sqlite:"filename"`
+	IsDir    bool      `sqlite:"isdir"`
+	Ext      string    `sqlite:"ext"`
+	ModTime  time.Time `sqlite:"modtime"`
+	Size     int64     `sqlite:"size"`
+}
+
 type FileResponse struct {
 	Path     string    `json:"path"`
 	Parent   string    `json:"parent"`
@@ -105,9 +118,9 @@ func (p *plugin) AddHandlers(ctx context.Context, provider Provider) error {
 
 func (p *plugin) ServePing(w http.ResponseWriter, req *http.Request) {
 	// Get a connection
-	conn := p.pool.Get()
-	if conn == nil {
-		router.ServeError(w, http.StatusBadGateway, "No connection")
+	conn, err := p.pool.GetContext(req.Context())
+	if err != nil {
+		router.ServeError(w, http.StatusServiceUnavailable, "No connection", err.Error())
 		return
 	}
 	defer p.pool.Put(conn)
@@ -152,9 +165,9 @@ func (p *plugin) ServePing(w http.ResponseWriter, req *http.Request) {
 
 func (p *plugin) ServeQuery(w http.ResponseWriter, req *http.Request) {
 	// Get a connection
-	conn := p.pool.Get()
-	if conn == nil {
-		router.ServeError(w, http.StatusBadGateway, "No connection")
+	conn, err := p.pool.GetContext(req.Context())
+	if err != nil {
+		router.ServeError(w, http.StatusServiceUnavailable, "No connection", err.Error())
 		return
 	}
 	defer p.pool.Put(conn)
@@ -187,37 +200,35 @@ func (p *plugin) ServeQuery(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Perform the query and collate the results
-	if err := conn.Do(req.Context(), 0, func(txn SQTransaction) error {
+	if err := conn.Do(req.Context(), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
 		q := indexer.Query(p.store.Schema(), query.Snippet).WithLimitOffset(query.Limit, query.Offset)
 		r, err := txn.Query(q, query.Query)
 		if err != nil {
 			return err
 		}
-		n := int64(0)
-		for {
-			rows := r.Next(nil, nil, nil, nil, nil, nil, nil, nil, nil, reflect.TypeOf(time.Time{}))
-			if rows == nil {
-				return nil
-			} else {
-				n = n + 1
-			}
+		var rows []queryRow
+		if err := r.ScanAll(&rows); err != nil {
+			return err
+		}
+		for i, row := range rows {
 			response.Results = append(response.Results, ResultResponse{
-				Id:      rows[0].(int64),
-				Offset:  n + int64(query.Offset) - 1,
-				Rank:    rows[1].(float64),
-				Snippet: rows[2].(string),
-				Index:   rows[3].(string),
+				Id:      row.Id,
+				Offset:  int64(i) + int64(query.Offset),
+				Rank:    row.Rank,
+				Snippet: row.Snippet,
+				Index:   row.Index,
 				File: FileResponse{
-					Path:     rows[4].(string),
-					Parent:   rows[5].(string),
-					Filename: rows[6].(string),
-					IsDir:    int64ToBool(rows[7].(int64)),
-					Ext:      rows[8].(string),
-					ModTime:  rows[9].(time.Time),
-					Size:     rows[10].(int64),
+					Path:     row.Path,
+					Parent:   row.Parent,
+					Filename: row.Filename,
+					IsDir:    row.IsDir,
+					Ext:      row.Ext,
+					ModTime:  row.ModTime,
+					Size:     row.Size,
 				},
 			})
 		}
+		return nil
 	}); err != nil {
 		router.ServeError(w, http.StatusBadRequest, err.Error())
 		return
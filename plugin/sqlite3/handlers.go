@@ -13,6 +13,8 @@ import (
 
 	// Packages
 	router "github.com/mutablelogic/go-server/pkg/httprouter"
+	provider "github.com/mutablelogic/go-server/pkg/provider"
+	policy "github.com/mutablelogic/go-sqlite/pkg/policy"
 	sqlite3 "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
 	tokenizer "github.com/mutablelogic/go-sqlite/pkg/tokenizer"
 
@@ -46,20 +48,29 @@ type SchemaResponse struct {
 }
 
 type SchemaTableResponse struct {
-	Name    string                 `json:"name"`
-	Schema  string                 `json:"schema"`
-	Count   int64                  `json:"count"`
-	Indexes []SchemaIndexResponse  `json:"indexes,omitempty"`
-	Columns []SchemaColumnResponse `json:"columns,omitempty"`
+	Name        string                     `json:"name"`
+	Schema      string                     `json:"schema"`
+	Count       int64                      `json:"count"`
+	Sql         string                     `json:"sql,omitempty"`
+	Indexes     []SchemaIndexResponse      `json:"indexes,omitempty"`
+	Columns     []SchemaColumnResponse     `json:"columns,omitempty"`
+	ForeignKeys []SchemaForeignKeyResponse `json:"foreign_keys,omitempty"`
+	Triggers    []string                   `json:"triggers,omitempty"`
 }
 
 type SchemaColumnResponse struct {
-	Name     string `json:"name"`
-	Table    string `json:"table,omitempty"`
-	Schema   string `json:"schema,omitempty"`
-	Type     string `json:"type,omitempty"`
-	Primary  bool   `json:"primary,omitempty"`
-	Nullable bool   `json:"nullable,omitempty"`
+	Name          string `json:"name"`
+	Table         string `json:"table,omitempty"`
+	Schema        string `json:"schema,omitempty"`
+	Type          string `json:"type,omitempty"`
+	Affinity      string `json:"affinity,omitempty"`
+	Default       string `json:"default,omitempty"`
+	Collation     string `json:"collation,omitempty"`
+	Generated     string `json:"generated,omitempty"`
+	Primary       bool   `json:"primary,omitempty"`
+	AutoIncrement bool   `json:"autoincrement,omitempty"`
+	Nullable      bool   `json:"nullable,omitempty"`
+	Hidden        bool   `json:"hidden,omitempty"`
 }
 
 type SchemaIndexResponse struct {
@@ -68,6 +79,13 @@ type SchemaIndexResponse struct {
 	Columns []string `json:"columns"`
 }
 
+type SchemaForeignKeyResponse struct {
+	Table   string   `json:"table"`
+	Keys    []string `json:"keys"`
+	Columns []string `json:"columns,omitempty"`
+	Sql     string   `json:"sql"`
+}
+
 type SqlRequest struct {
 	Sql string `json:"sql"`
 }
@@ -82,6 +100,20 @@ type SqlResultResponse struct {
 	Results      []interface{}          `json:"results,omitempty"`
 }
 
+type ProfileResponse struct {
+	Slow     []ProfileQueryResponse `json:"slow"`
+	Frequent []ProfileQueryResponse `json:"frequent"`
+}
+
+type ProfileQueryResponse struct {
+	Sql   string  `json:"sql"`
+	Count int     `json:"count"`
+	Min   int64   `json:"min_ns"`
+	Mean  int64   `json:"mean_ns"`
+	Max   int64   `json:"max_ns"`
+	Rate  float64 `json:"ops_per_sec"`
+}
+
 type TokenizerResponse struct {
 	Html     []template.HTML `json:"html,omitempty"`
 	Complete bool            `json:"complete"`
@@ -96,13 +128,17 @@ var (
 	reRouteTable     = regexp.MustCompile(`^/([a-zA-Z][a-zA-Z0-9_-]+)/([^/]+)/?$`)
 	reRouteTokenizer = regexp.MustCompile(`^/-/tokenizer/?$`)
 	reRouteQuery     = regexp.MustCompile(`^/-/q/?$`)
+	reRouteMetrics   = regexp.MustCompile(`^/-/metrics/?$`)
+	reRouteProfile   = regexp.MustCompile(`^/-/profile/?$`)
 )
 
 ///////////////////////////////////////////////////////////////////////////////
 // CONSTANTS
 
 const (
-	maxResultLimit = 1000
+	maxResultLimit  = 1000
+	defaultProfiles = 10
+	maxProfiles     = 100
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -134,6 +170,16 @@ func (p *plugin) AddHandlers(ctx context.Context, provider Provider) error {
 		return err
 	}
 
+	// Add handler for metrics
+	if err := provider.AddHandlerFuncEx(ctx, reRouteMetrics, p.ServeMetrics); err != nil {
+		return err
+	}
+
+	// Add handler for query profiles
+	if err := provider.AddHandlerFuncEx(ctx, reRouteProfile, p.ServeProfile); err != nil {
+		return err
+	}
+
 	// Return success
 	return nil
 }
@@ -143,9 +189,9 @@ func (p *plugin) AddHandlers(ctx context.Context, provider Provider) error {
 
 func (p *plugin) ServePing(w http.ResponseWriter, req *http.Request) {
 	// Get a connection
-	conn := p.Get()
-	if conn == nil {
-		router.ServeError(w, http.StatusBadGateway, "No connection")
+	conn, err := p.GetContext(req.Context())
+	if err != nil {
+		router.ServeError(w, http.StatusServiceUnavailable, "No connection", err.Error())
 		return
 	}
 	defer p.Put(conn)
@@ -169,9 +215,9 @@ func (p *plugin) ServeSchema(w http.ResponseWriter, req *http.Request) {
 	params := router.RequestParams(req)
 
 	// Get a connection
-	conn := p.Get()
-	if conn == nil {
-		router.ServeError(w, http.StatusBadGateway, "No connection")
+	conn, err := p.GetContext(req.Context())
+	if err != nil {
+		router.ServeError(w, http.StatusServiceUnavailable, "No connection", err.Error())
 		return
 	}
 	defer p.Put(conn)
@@ -200,6 +246,7 @@ func (p *plugin) ServeSchema(w http.ResponseWriter, req *http.Request) {
 			Name:    name,
 			Schema:  params[0],
 			Count:   conn.Count(params[0], name),
+			Sql:     conn.CreateStatement(params[0], name),
 			Columns: []SchemaColumnResponse{},
 			Indexes: []SchemaIndexResponse{},
 		}
@@ -213,6 +260,17 @@ func (p *plugin) ServeSchema(w http.ResponseWriter, req *http.Request) {
 		for _, column := range conn.ColumnsForTable(params[0], name) {
 			table.Columns = append(table.Columns, schemaColumn(params[0], name, column))
 		}
+		for _, fk := range conn.ForeignKeysForTable(params[0], name) {
+			table.ForeignKeys = append(table.ForeignKeys, SchemaForeignKeyResponse{
+				Table:   fk.Table(),
+				Keys:    fk.Keys(),
+				Columns: fk.Columns(),
+				Sql:     fmt.Sprint(fk),
+			})
+		}
+		for _, trigger := range conn.TriggersForTable(params[0], name) {
+			table.Triggers = append(table.Triggers, trigger.Query())
+		}
 		response.Tables = append(response.Tables, table)
 	}
 
@@ -220,6 +278,43 @@ func (p *plugin) ServeSchema(w http.ResponseWriter, req *http.Request) {
 	router.ServeJSON(w, response, http.StatusOK, 2)
 }
 
+func (p *plugin) ServeProfile(w http.ResponseWriter, req *http.Request) {
+	// Query parameters
+	var q struct {
+		Limit uint `json:"limit"`
+	}
+	if err := router.RequestQuery(req, &q); err != nil {
+		router.ServeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultProfiles
+	}
+	q.Limit = uintMin(q.Limit, maxProfiles)
+
+	// Check for profiling
+	pool, ok := p.pool.(*sqlite3.Pool)
+	if !ok || !p.profile {
+		router.ServeError(w, http.StatusNotImplemented, "Profiling not enabled")
+		return
+	}
+
+	// Populate response
+	response := ProfileResponse{
+		Slow:     []ProfileQueryResponse{},
+		Frequent: []ProfileQueryResponse{},
+	}
+	for _, s := range pool.SlowQueries(int(q.Limit)) {
+		response.Slow = append(response.Slow, ProfileQueryResponse{s.SQL(), s.Count(), s.Min().Nanoseconds(), s.Mean().Nanoseconds(), s.Max().Nanoseconds(), s.Rate()})
+	}
+	for _, s := range pool.FrequentQueries(int(q.Limit)) {
+		response.Frequent = append(response.Frequent, ProfileQueryResponse{s.SQL(), s.Count(), s.Min().Nanoseconds(), s.Mean().Nanoseconds(), s.Max().Nanoseconds(), s.Rate()})
+	}
+
+	// Serve response
+	router.ServeJSON(w, response, http.StatusOK, 2)
+}
+
 func (p *plugin) ServeTable(w http.ResponseWriter, req *http.Request) {
 	// Query parameters
 	var q struct {
@@ -237,9 +332,9 @@ func (p *plugin) ServeTable(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Get a connection
-	conn := p.Get()
-	if conn == nil {
-		router.ServeError(w, http.StatusBadGateway, "No connection")
+	conn, err := p.GetContext(req.Context())
+	if err != nil {
+		router.ServeError(w, http.StatusServiceUnavailable, "No connection", err.Error())
 		return
 	}
 	defer p.Put(conn)
@@ -258,11 +353,12 @@ func (p *plugin) ServeTable(w http.ResponseWriter, req *http.Request) {
 
 	// Populate response
 	var response SqlResultResponse
-	if err := conn.Do(req.Context(), SQLITE_TXN_DEFAULT, func(txn SQTransaction) error {
+	if err := conn.Do(p.context(req), SQLITE_TXN_READONLY, func(txn SQTransaction) error {
 		r, err := txn.Query(S(N(params[1]).WithSchema(params[0])).WithLimitOffset(q.Limit, q.Offset))
 		if err != nil {
 			return err
 		}
+		defer r.Close()
 		if r, err := results(r); err != nil {
 			return err
 		} else {
@@ -290,9 +386,9 @@ func (p *plugin) ServeTokenizer(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Get a connection
-	conn := p.Get()
-	if conn == nil {
-		router.ServeError(w, http.StatusBadGateway, "No connection")
+	conn, err := p.GetContext(req.Context())
+	if err != nil {
+		router.ServeError(w, http.StatusServiceUnavailable, "No connection", err.Error())
 		return
 	}
 	defer p.Put(conn)
@@ -323,20 +419,25 @@ func (p *plugin) ServeQuery(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Get a connection
-	conn := p.Get()
-	if conn == nil {
-		router.ServeError(w, http.StatusBadGateway, "No connection")
+	conn, err := p.GetContext(req.Context())
+	if err != nil {
+		router.ServeError(w, http.StatusServiceUnavailable, "No connection", err.Error())
 		return
 	}
 	defer p.Put(conn)
 
-	// Perform query
+	// Perform query, which cannot modify the database in read-only mode
+	flags := SQLITE_TXN_DEFAULT
+	if p.readonly {
+		flags = SQLITE_TXN_READONLY
+	}
 	response := make([]SqlResultResponse, 0, 2)
-	if err := conn.Do(req.Context(), SQLITE_TXN_DEFAULT, func(txn SQTransaction) error {
+	if err := conn.Do(p.context(req), flags, func(txn SQTransaction) error {
 		r, err := txn.Query(Q(query.Sql))
 		if err != nil {
 			return err
 		}
+		defer r.Close()
 		for {
 			if r, err := results(r); err != nil {
 				return err
@@ -363,16 +464,35 @@ func (p *plugin) ServeQuery(w http.ResponseWriter, req *http.Request) {
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
 
+// context returns the request context with the role of the authenticated
+// user, which is used to authorize statements when a policy is set
+func (p *plugin) context(req *http.Request) context.Context {
+	ctx := req.Context()
+	if p.policy == nil {
+		return ctx
+	}
+	return policy.WithRole(ctx, p.policy.RoleForUser(provider.ContextUser(ctx)))
+}
+
 func schemaColumn(schema, table string, column SQColumn) SchemaColumnResponse {
 	result := SchemaColumnResponse{
-		Name:   column.Name(),
-		Table:  table,
-		Schema: schema,
-		Type:   column.Type(),
+		Name:          column.Name(),
+		Table:         table,
+		Schema:        schema,
+		Type:          column.Type(),
+		Affinity:      column.Affinity(),
+		Collation:     column.Collation(),
+		Generated:     column.Generated(),
+		AutoIncrement: column.AutoIncrement(),
+		Nullable:      column.Nullable(),
+		Hidden:        column.Hidden(),
 	}
 	if column.Primary() != "" {
 		result.Primary = true
 	}
+	if def := column.Default(); def != nil {
+		result.Default = fmt.Sprint(def)
+	}
 	return result
 }
 
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	// Packages
+	policy "github.com/mutablelogic/go-sqlite/pkg/policy"
 	sqlite3 "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
 
 	// Namespace imports
@@ -25,11 +26,20 @@ type Config struct {
 	Max       int               `yaml:"max"`
 	Create    bool              `yaml:"create"`
 	Trace     bool              `yaml:"trace"`
+	Profile   bool              `yaml:"profile"`
+	WAL       bool              `yaml:"wal"`
+	Busy      time.Duration     `yaml:"busy_timeout"`
+	Retry     int               `yaml:"retry"`
+	ReadOnly  bool              `yaml:"readonly"`
+	Policy    string            `yaml:"policy"`
 }
 
 type plugin struct {
-	pool SQPool
-	errs chan error
+	pool     SQPool
+	errs     chan error
+	readonly bool
+	profile  bool
+	policy   *policy.Policy
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -53,10 +63,24 @@ func New(ctx context.Context, provider Provider) Plugin {
 	// Create the pool
 	poolcfg := sqlite3.NewConfig().
 		WithMaxConnections(cfg.Max).
-		WithCreate(cfg.Create)
+		WithCreate(cfg.Create).
+		WithWAL(cfg.WAL).
+		WithBusyTimeout(cfg.Busy).
+		WithRetry(sqlite3.NewRetryPolicy(cfg.Retry)).
+		WithReadOnly(cfg.ReadOnly).
+		WithProfile(cfg.Profile)
 	for name, path := range cfg.Databases {
 		poolcfg = poolcfg.WithSchema(name, path)
 	}
+	if cfg.Policy != "" {
+		if auth, err := policy.Read(cfg.Policy); err != nil {
+			provider.Print(ctx, err)
+			return nil
+		} else {
+			p.policy = auth
+			poolcfg = poolcfg.WithAuth(auth)
+		}
+	}
 	if cfg.Trace {
 		poolcfg = poolcfg.WithTrace(func(c *sqlite3.Conn, q string, d time.Duration) {
 			if d >= 0 {
@@ -67,6 +91,8 @@ func New(ctx context.Context, provider Provider) Plugin {
 
 	// Create a channel for errors
 	p.errs = make(chan error)
+	p.readonly = cfg.ReadOnly
+	p.profile = cfg.Profile
 
 	// Create a pool
 	if pool, err := sqlite3.OpenPool(poolcfg, p.errs); err != nil {
@@ -138,6 +164,10 @@ func (p *plugin) Get() SQConnection {
 	return p.pool.Get()
 }
 
+func (p *plugin) GetContext(ctx context.Context) (SQConnection, error) {
+	return p.pool.GetContext(ctx)
+}
+
 func (p *plugin) Put(conn SQConnection) {
 	p.pool.Put(conn)
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	// Packages
+	router "github.com/mutablelogic/go-server/pkg/httprouter"
+	sqlite3 "github.com/mutablelogic/go-sqlite/pkg/sqlite3"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	metricsPrefix      = "sqlite_"
+	metricsContentType = "text/plain; version=0.0.4"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// HANDLERS
+
+// ServeMetrics returns pool statistics in Prometheus text format
+func (p *plugin) ServeMetrics(w http.ResponseWriter, req *http.Request) {
+	pool, ok := p.pool.(*sqlite3.Pool)
+	if !ok {
+		router.ServeError(w, http.StatusNotImplemented, "Metrics not supported")
+		return
+	}
+	stats := pool.Stats()
+
+	// Write metrics
+	w.Header().Set("Content-Type", metricsContentType)
+	w.WriteHeader(http.StatusOK)
+	writeMetric(w, "pool_open_connections", "gauge", "Number of open connections", stats.Open)
+	writeMetric(w, "pool_idle_connections", "gauge", "Number of idle connections", stats.Idle)
+	writeMetric(w, "pool_in_use_connections", "gauge", "Number of connections checked out", stats.InUse)
+	writeMetric(w, "pool_max_connections", "gauge", "Maximum number of connections", pool.Max())
+	writeMetric(w, "pool_checkouts_total", "counter", "Total number of connections checked out", stats.Checkouts)
+	writeMetric(w, "pool_wait_total", "counter", "Total number of times a caller waited for a connection", stats.WaitCount)
+	writeMetric(w, "pool_wait_seconds_total", "counter", "Total time callers waited for a connection", stats.WaitDuration.Seconds())
+	writeLabelledMetric(w, "pool_closed_total", "counter", "Total number of connections closed", "reason", stats.Closed)
+	writeMetric(w, "cache_hits_total", "counter", "Total number of prepared statement cache hits", stats.CacheHits)
+	writeMetric(w, "cache_misses_total", "counter", "Total number of prepared statement cache misses", stats.CacheMisses)
+	writeMetric(w, "cache_evictions_total", "counter", "Total number of prepared statement cache evictions", stats.CacheEvictions)
+	writeMetric(w, "memory_used_bytes", "gauge", "Memory used by sqlite", stats.MemoryUsed)
+	writeMetric(w, "memory_highwater_bytes", "gauge", "Maximum memory used by sqlite", stats.MemoryHighwater)
+	writeMetric(w, "page_cache_used_bytes", "gauge", "Memory used by the page cache of idle connections", stats.PageCacheUsed)
+	writeMetric(w, "schema_used_bytes", "gauge", "Memory used by the schema of idle connections", stats.SchemaUsed)
+	writeMetric(w, "statement_used_bytes", "gauge", "Memory used by prepared statements of idle connections", stats.StatementUsed)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func writeMetric(w io.Writer, name, t, help string, value interface{}) {
+	name = metricsPrefix + name
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, t)
+	fmt.Fprintln(w, name, value)
+}
+
+func writeLabelledMetric(w io.Writer, name, t, help, label string, values map[string]int64) {
+	name = metricsPrefix + name
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, t)
+
+	// Write values in label order
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, strings.ReplaceAll(key, "\n", " "), values[key])
+	}
+}
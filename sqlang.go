@@ -65,12 +65,23 @@ type SQJoin interface {
 type SQTable interface {
 	SQStatement
 
+	// Return properties
+	Name() string
+	Schema() string
+	Columns() []SQColumn
+
+	// Modifiers
+	WithName(string) SQTable
+	WithSchema(string) SQTable
+	WithColumn(...SQColumn) SQTable
 	IfNotExists() SQTable
 	WithTemporary() SQTable
 	WithoutRowID() SQTable
+	WithStrict() SQTable
 	WithIndex(...string) SQTable
 	WithUnique(...string) SQTable
 	WithForeignKey(SQForeignKey, ...string) SQTable
+	WithCheck(SQExpr) SQTable
 }
 
 // SQUpdate defines an update statement
@@ -120,6 +131,7 @@ type SQTrigger interface {
 	Delete() SQTrigger
 	Insert() SQTrigger
 	Update(...string) SQTrigger
+	When(SQExpr) SQTrigger
 }
 
 // SQDrop defines a drop for tables, views, indexes, and triggers
@@ -136,6 +148,7 @@ type SQInsert interface {
 	DefaultValues() SQInsert
 	WithConflictDoNothing(...string) SQInsert
 	WithConflictUpdate(...string) SQInsert
+	WithRows(int) SQInsert
 }
 
 // SQSelect defines a select statement
@@ -165,7 +178,15 @@ type SQAlter interface {
 
 // SQForeignKey represents a foreign key constraint
 type SQForeignKey interface {
+	// Properties
+	Table() string     // Parent table
+	Columns() []string // Parent key columns
+	Keys() []string    // Child key columns
+
 	// Modifiers
+	WithKeys(...string) SQForeignKey
+	OnDelete(string) SQForeignKey
+	OnUpdate(string) SQForeignKey
 	OnDeleteCascade() SQForeignKey
 }
 
@@ -178,6 +199,12 @@ type SQColumn interface {
 	Type() string
 	Nullable() bool
 	Primary() string
+	AutoIncrement() bool
+	Default() SQExpr
+	Collation() string
+	Hidden() bool
+	Generated() string
+	Affinity() string
 
 	// Modifiers
 	NotNull() SQColumn
@@ -187,6 +214,9 @@ type SQColumn interface {
 	WithAutoIncrement() SQColumn
 	WithDefault(v interface{}) SQColumn
 	WithDefaultNow() SQColumn
+	WithCollation(string) SQColumn
+	WithHidden() SQColumn
+	WithGenerated(SQExpr, bool) SQColumn
 }
 
 // SQExpr defines any expression
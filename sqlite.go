@@ -2,6 +2,7 @@ package sqlite
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"strings"
 )
@@ -29,10 +30,16 @@ type SQPool interface {
 	// Close waits for all connections to be released and then releases resources
 	Close() error
 
-	// Get a connection from the pool. If there are no
-	// connections available or an error occurs, nil is returned.
+	// Get a connection from the pool. If all connections are checked out,
+	// waits up to the configured timeout for a connection to be released.
+	// If no connection could be obtained or an error occurs, nil is returned.
 	Get() SQConnection
 
+	// GetContext gets a connection from the pool, waiting for a connection
+	// to be released if none are available. Returns an error if the context
+	// is cancelled or the wait times out.
+	GetContext(context.Context) (SQConnection, error)
+
 	// Return connection to the pool
 	Put(SQConnection)
 
@@ -58,6 +65,10 @@ type SQConnection interface {
 	// Execute a statement outside transacton
 	Exec(SQStatement, SQExecFunc) error
 
+	// Query outside a transaction with context, cancelling any statement
+	// in progress when the context is done
+	QueryContext(context.Context, SQStatement, ...interface{}) (SQResults, error)
+
 	// Return a unique counter number for the connection
 	Counter() int64
 }
@@ -67,6 +78,11 @@ type SQTransaction interface {
 	// Query and return a set of results
 	Query(SQStatement, ...interface{}) (SQResults, error)
 
+	// Execute a nested transaction with context. Within a transaction,
+	// a savepoint is created which is released on success, or rolled
+	// back to on any errors or cancelled context
+	Do(context.Context, SQFlag, func(SQTransaction) error) error
+
 	// Schemas returns a list of all the schemas in the database
 	Schemas() []string
 
@@ -92,13 +108,51 @@ type SQTransaction interface {
 	// Views returns a list of view names in a schema
 	Views(string) []string
 
+	// Triggers returns a list of trigger names in a schema
+	Triggers(string) []string
+
+	// TriggersForTable returns the triggers associated with a schema and table
+	TriggersForTable(string, string) []SQTrigger
+
+	// ForeignKeysForTable returns the foreign key constraints for a schema
+	// and table
+	ForeignKeysForTable(string, string) []SQForeignKey
+
+	// TableDefinition returns the definition of a table in a schema,
+	// including constraints and table options. Returns nil if the table
+	// does not exist or is a virtual table
+	TableDefinition(string, string) SQTable
+
+	// CreateStatement returns the original CREATE statement for a table,
+	// index, view or trigger in a schema, or empty string if the object
+	// does not exist or was created automatically
+	CreateStatement(string, string) string
+
 	// Modules returns a list of modules. If an argument is
 	// provided, then only modules with those name prefixes
 	// matched
 	Modules(...string) []string
 
+	// Functions returns a list of functions. If an argument is
+	// provided, then only functions with those name prefixes
+	// matched
+	Functions(...string) []string
+
+	// Collations returns a list of collating sequences. If an argument
+	// is provided, then only collations with those name prefixes
+	// matched
+	Collations(...string) []string
+
 	// Return flags for transaction or'd with connection flags
 	Flags() SQFlag
+
+	// OnCommit registers a function to be called once the transaction
+	// has been committed
+	OnCommit(func())
+
+	// OnRollback registers a function to be called once the transaction
+	// has been rolled back
+	OnRollback(func())
 }
 
 // SQResults increments over returned rows from a query
@@ -129,6 +183,18 @@ type SQResults interface {
 
 	// ColumnTable returns the schema, table and column name for a column index
 	ColumnSource(int) (string, string, string)
+
+	// ScanStruct reads the next row into a pointer to a struct, matching
+	// columns to fields by tag or name. Returns io.EOF when all rows
+	// have been read
+	ScanStruct(interface{}) error
+
+	// ScanMap reads the next row into a map of column names to values.
+	// Returns io.EOF when all rows have been read
+	ScanMap() (map[string]interface{}, error)
+
+	// ScanAll reads all remaining rows into a pointer to a slice of structs
+	ScanAll(interface{}) error
 }
 
 // SQAuth is an interface for authenticating an action
@@ -136,16 +202,26 @@ type SQAuth interface {
 	// CanSelect is called to authenticate a SELECT
 	CanSelect(context.Context) error
 
-	// CanTransaction is called for BEGIN, COMMIT, or ROLLBACK
+	// CanTransaction is called for BEGIN, COMMIT, or ROLLBACK with the
+	// SQLITE_AUTH_TRANSACTION flag set. For a savepoint, the SQLITE_AUTH_SAVEPOINT
+	// flag is also set, and RELEASE is reported as COMMIT
 	CanTransaction(context.Context, SQAuthFlag) error
 
-	// CanExec is called to authenticate an operation other then SELECT
+	// CanExec is called to authenticate an operation other then SELECT. For
+	// SQLITE_AUTH_READ, returning ErrAuthMask allows the statement but the
+	// column is read as NULL.
 	CanExec(context.Context, SQAuthFlag, string, ...string) error
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // GLOBALS
 
+var (
+	// ErrAuthMask is returned (or wrapped) by SQAuth.CanExec to mask a column
+	// when reading, rather than denying the statement
+	ErrAuthMask = errors.New("column is masked")
+)
+
 const (
 	SQLITE_NONE                          SQFlag = 0
 	SQLITE_TXN_DEFAULT                   SQFlag = (1 << 16) // Default transaction flag
@@ -155,6 +231,7 @@ const (
 	SQLITE_OPEN_CACHE                    SQFlag = (1 << 20) // Cache prepared statements
 	SQLITE_OPEN_OVERWRITE                SQFlag = (1 << 21) // Overwrite objects
 	SQLITE_OPEN_FOREIGNKEYS              SQFlag = (1 << 22) // Enable foreign key support
+	SQLITE_TXN_READONLY                  SQFlag = (1 << 23) // Read-only transaction
 )
 
 const (
@@ -178,8 +255,12 @@ const (
 	SQLITE_AUTH_BEGIN                              // Begin txn operation
 	SQLITE_AUTH_COMMIT                             // Commit txn operation
 	SQLITE_AUTH_ROLLBACK                           // Rollback txn operation
+	SQLITE_AUTH_SAVEPOINT                          // Savepoint operation
+	SQLITE_AUTH_ATTACH                             // Attach database operation
+	SQLITE_AUTH_DETACH                             // Detach database operation
+	SQLITE_AUTH_REINDEX                            // Reindex operation
 	SQLITE_AUTH_MIN                    = SQLITE_AUTH_TABLE
-	SQLITE_AUTH_MAX                    = SQLITE_AUTH_ROLLBACK
+	SQLITE_AUTH_MAX                    = SQLITE_AUTH_REINDEX
 	SQLITE_AUTH_NONE        SQAuthFlag = 0
 )
 
@@ -230,6 +311,14 @@ func (v SQAuthFlag) StringFlag() string {
 		return "SQLITE_AUTH_COMMIT"
 	case SQLITE_AUTH_ROLLBACK:
 		return "SQLITE_AUTH_ROLLBACK"
+	case SQLITE_AUTH_SAVEPOINT:
+		return "SQLITE_AUTH_SAVEPOINT"
+	case SQLITE_AUTH_ATTACH:
+		return "SQLITE_AUTH_ATTACH"
+	case SQLITE_AUTH_DETACH:
+		return "SQLITE_AUTH_DETACH"
+	case SQLITE_AUTH_REINDEX:
+		return "SQLITE_AUTH_REINDEX"
 	default:
 		return "[?? Invalid SQAuthFlag value]"
 	}
@@ -0,0 +1,62 @@
+package sqlite3
+
+import (
+	"unsafe"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CGO
+
+/*
+#include <sqlite3.h>
+#include <stdlib.h>
+
+extern int go_collation_callback(void*, int, void*, int, void*);
+extern void go_destroy_callback(void*);
+
+static inline int _sqlite3_create_collation_v2(sqlite3 *db,const char *name,void* userInfo) {
+	return sqlite3_create_collation_v2(db,name,SQLITE_UTF8,userInfo,(int(*)(void*,int,const void*,int,const void*))go_collation_callback,go_destroy_callback);
+}
+*/
+import "C"
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Create a collating sequence, which compares two strings and returns a
+// negative number, zero or a positive number
+func (c *ConnEx) CreateCollation(name string, fn CollationFunc) error {
+	// Convert name to C string
+	var cName *C.char
+	cName = C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	// Set function
+	userInfo := newUserInfo(setMapFunc(function{Compare: fn}))
+
+	// Call create, the destroy callback is not called when this fails
+	if err := SQError(C._sqlite3_create_collation_v2((*C.sqlite3)(c.Conn), cName, userInfo)); err != SQLITE_OK {
+		go_destroy_callback(userInfo)
+		return err
+	}
+
+	// Return success
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+//export go_collation_callback
+func go_collation_callback(userInfo unsafe.Pointer, na C.int, a unsafe.Pointer, nb C.int, b unsafe.Pointer) C.int {
+	id := userInfoId(userInfo)
+
+	mapFuncLock.RLock()
+	fn, exists := mapFunc[id]
+	mapFuncLock.RUnlock()
+
+	if exists && fn.Compare != nil {
+		return C.int(fn.Compare(C.GoStringN((*C.char)(a), na), C.GoStringN((*C.char)(b), nb)))
+	}
+	return 0
+}
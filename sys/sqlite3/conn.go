@@ -159,11 +159,11 @@ func OpenPath(path string, flags OpenFlags, vfs string) (*Conn, error) {
 		flags |= SQLITE_OPEN_MEMORY
 	}
 
-	// Set flags, add read/write flag if create flag is set
+	// Set flags, add read/write flag unless read-only flag is set
 	if flags == 0 {
 		flags = DefaultFlags
 	}
-	if flags|SQLITE_OPEN_CREATE > 0 {
+	if flags&SQLITE_OPEN_READONLY == 0 {
 		flags |= SQLITE_OPEN_READWRITE
 	}
 	// Remove custom flags, which are not supported by sqlite3_open_v2
@@ -312,6 +312,11 @@ func (c *Conn) Changes() int {
 	return int(C.sqlite3_changes((*C.sqlite3)(c)))
 }
 
+// Get total number of changes (rows affected) since the connection was opened
+func (c *Conn) TotalChanges() int {
+	return int(C.sqlite3_total_changes((*C.sqlite3)(c)))
+}
+
 // Interrupt all queries for connection
 func (c *Conn) Interrupt() {
 	C.sqlite3_interrupt((*C.sqlite3)(c))
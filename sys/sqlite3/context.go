@@ -48,6 +48,12 @@ func (ctx *Context) String() string {
 ///////////////////////////////////////////////////////////////////////////////
 // PUBLIC METHODS
 
+// AggregateId returns an identifier for the aggregate being computed, which
+// is the same for each step and the final call
+func (ctx *Context) AggregateId() uintptr {
+	return uintptr(C.sqlite3_aggregate_context((*C.sqlite3_context)(ctx), 8))
+}
+
 // Set error as too big, indicating that a string or BLOB is too long to represent
 func (ctx *Context) ErrTooBig() {
 	C.sqlite3_result_error_toobig((*C.sqlite3_context)(ctx))
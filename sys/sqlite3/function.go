@@ -11,6 +11,7 @@ import (
 
 /*
 #include <sqlite3.h>
+#include <stdint.h>
 #include <stdlib.h>
 
 extern void go_func_callback(sqlite3_context*, int, sqlite3_value**);
@@ -32,14 +33,16 @@ import "C"
 // TYPES
 
 type (
-	StepFunc  func(*Context, []*Value)
-	FinalFunc func(*Context)
+	StepFunc      func(*Context, []*Value)
+	FinalFunc     func(*Context)
+	CollationFunc func(a, b string) int
 )
 
 type function struct {
-	Func  StepFunc
-	Step  StepFunc
-	Final FinalFunc
+	Func    StepFunc
+	Step    StepFunc
+	Final   FinalFunc
+	Compare CollationFunc
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -71,7 +74,7 @@ func (c *ConnEx) CreateScalarFunction(name string, nargs int, deterministic bool
 	userInfo := setMapFunc(function{Func: fn})
 
 	// Call create
-	if err := SQError(C._sqlite3_create_function_v2_scalar((*C.sqlite3)(c.Conn), cName, C.int(nargs), flags, unsafe.Pointer(uintptr(userInfo)))); err != SQLITE_OK {
+	if err := SQError(C._sqlite3_create_function_v2_scalar((*C.sqlite3)(c.Conn), cName, C.int(nargs), flags, newUserInfo(userInfo))); err != SQLITE_OK {
 		return err
 	}
 
@@ -79,7 +82,32 @@ func (c *ConnEx) CreateScalarFunction(name string, nargs int, deterministic bool
 	return nil
 }
 
-// TODO: CreateAggregateFunction
+// Create a custom aggregate function. The step function is called for each
+// row, and the final function is called to return the result. Use
+// Context.AggregateId to identify the aggregate being computed.
+func (c *ConnEx) CreateAggregateFunction(name string, nargs int, deterministic bool, step StepFunc, final FinalFunc) error {
+	// Convert name to C string
+	var cName *C.char
+	cName = C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	// Set deterministic
+	flags := C.int(C.SQLITE_UTF8)
+	if deterministic {
+		flags |= C.SQLITE_DETERMINISTIC
+	}
+
+	// Set function
+	userInfo := setMapFunc(function{Step: step, Final: final})
+
+	// Call create
+	if err := SQError(C._sqlite3_create_function_v2_aggregate((*C.sqlite3)(c.Conn), cName, C.int(nargs), flags, newUserInfo(userInfo))); err != SQLITE_OK {
+		return err
+	}
+
+	// Return success
+	return nil
+}
 
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE METHODS
@@ -92,6 +120,19 @@ func setMapFunc(fn function) int {
 	return id
 }
 
+// newUserInfo returns C memory holding a function identifier, which is
+// passed to sqlite as user data and freed by the destroy callback
+func newUserInfo(id int) unsafe.Pointer {
+	userInfo := (*C.uintptr_t)(C.malloc(C.size_t(unsafe.Sizeof(C.uintptr_t(0)))))
+	*userInfo = C.uintptr_t(id)
+	return unsafe.Pointer(userInfo)
+}
+
+// userInfoId returns the function identifier from user data
+func userInfoId(userInfo unsafe.Pointer) int {
+	return int(*(*C.uintptr_t)(userInfo))
+}
+
 func nextMapFuncId() int {
 	for {
 		mapFuncId = rand.Int()
@@ -113,7 +154,7 @@ func values(n int, v **C.sqlite3_value) []*Value {
 
 //export go_func_callback
 func go_func_callback(ctx *C.sqlite3_context, n C.int, v **C.sqlite3_value) {
-	id := int(uintptr(C.sqlite3_user_data(ctx)))
+	id := userInfoId(C.sqlite3_user_data(ctx))
 
 	mapFuncLock.RLock()
 	fn, exists := mapFunc[id]
@@ -126,7 +167,7 @@ func go_func_callback(ctx *C.sqlite3_context, n C.int, v **C.sqlite3_value) {
 
 //export go_step_callback
 func go_step_callback(ctx *C.sqlite3_context, n C.int, v **C.sqlite3_value) {
-	id := int(uintptr(C.sqlite3_user_data(ctx)))
+	id := userInfoId(C.sqlite3_user_data(ctx))
 
 	mapFuncLock.RLock()
 	fn, exists := mapFunc[id]
@@ -139,7 +180,7 @@ func go_step_callback(ctx *C.sqlite3_context, n C.int, v **C.sqlite3_value) {
 
 //export go_final_callback
 func go_final_callback(ctx *C.sqlite3_context) {
-	id := int(uintptr(C.sqlite3_user_data(ctx)))
+	id := userInfoId(C.sqlite3_user_data(ctx))
 
 	mapFuncLock.RLock()
 	fn, exists := mapFunc[id]
@@ -152,8 +193,9 @@ func go_final_callback(ctx *C.sqlite3_context) {
 
 //export go_destroy_callback
 func go_destroy_callback(userInfo unsafe.Pointer) {
-	id := int(uintptr(userInfo))
+	id := userInfoId(userInfo)
 	mapFuncLock.Lock()
 	delete(mapFunc, id)
 	mapFuncLock.Unlock()
+	C.free(userInfo)
 }
@@ -0,0 +1,88 @@
+package sqlite3
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CGO
+
+/*
+#include <sqlite3.h>
+#include <stdlib.h>
+*/
+import "C"
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// ColumnMetadata describes a column declared in a table
+type ColumnMetadata struct {
+	DeclType      string // Declared data type
+	Collation     string // Name of default collation sequence
+	NotNull       bool   // True if column has a NOT NULL constraint
+	Primary       bool   // True if column is part of the PRIMARY KEY
+	AutoIncrement bool   // True if column is AUTOINCREMENT
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (m ColumnMetadata) String() string {
+	str := "<column_metadata"
+	if m.DeclType != "" {
+		str += fmt.Sprintf(" decltype=%q", m.DeclType)
+	}
+	if m.Collation != "" {
+		str += fmt.Sprintf(" collation=%q", m.Collation)
+	}
+	if m.NotNull {
+		str += " notnull"
+	}
+	if m.Primary {
+		str += " primary"
+	}
+	if m.AutoIncrement {
+		str += " autoincrement"
+	}
+	return str + ">"
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// ColumnMetadata returns metadata about a specific column of a specific
+// database table. If schema is empty, then all attached databases are
+// searched for the table. Returns an error if the table or column does not
+// exist, or the table is a view.
+func (c *Conn) ColumnMetadata(schema, table, column string) (ColumnMetadata, error) {
+	var cSchema, cTable, cColumn, cDeclType, cCollation *C.char
+	var cNotNull, cPrimary, cAutoinc C.int
+	var result ColumnMetadata
+
+	// Populate CStrings
+	if schema != "" {
+		cSchema = C.CString(schema)
+		defer C.free(unsafe.Pointer(cSchema))
+	}
+	cTable = C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+	cColumn = C.CString(column)
+	defer C.free(unsafe.Pointer(cColumn))
+
+	// Call and return
+	if err := SQError(C.sqlite3_table_column_metadata((*C.sqlite3)(c), cSchema, cTable, cColumn, &cDeclType, &cCollation, &cNotNull, &cPrimary, &cAutoinc)); err != SQLITE_OK {
+		return result, err.With(C.GoString(C.sqlite3_errmsg((*C.sqlite3)(c))))
+	}
+
+	// Strings are owned by sqlite so copy them
+	result.DeclType = C.GoString(cDeclType)
+	result.Collation = C.GoString(cCollation)
+	result.NotNull = intToBool(int(cNotNull))
+	result.Primary = intToBool(int(cPrimary))
+	result.AutoIncrement = intToBool(int(cAutoinc))
+
+	// Return success
+	return result, nil
+}
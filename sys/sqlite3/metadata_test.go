@@ -0,0 +1,53 @@
+package sqlite3_test
+
+import (
+	"testing"
+
+	"github.com/mutablelogic/go-sqlite/sys/sqlite3"
+)
+
+func Test_Metadata_001(t *testing.T) {
+	db, err := sqlite3.OpenPath(":memory:", sqlite3.SQLITE_OPEN_CREATE, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a table
+	st, _, err := db.Prepare("CREATE TABLE test (a INTEGER PRIMARY KEY AUTOINCREMENT, b TEXT NOT NULL COLLATE NOCASE, c)")
+	if err != nil {
+		t.Fatal(err)
+	} else if err := st.Step(); err != sqlite3.SQLITE_DONE {
+		t.Fatal(err)
+	} else if err := st.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check metadata for each column
+	if meta, err := db.ColumnMetadata("main", "test", "a"); err != nil {
+		t.Error(err)
+	} else if meta.DeclType != "INTEGER" || !meta.Primary || !meta.AutoIncrement {
+		t.Error("Unexpected metadata", meta)
+	} else {
+		t.Log(meta)
+	}
+	if meta, err := db.ColumnMetadata("", "test", "b"); err != nil {
+		t.Error(err)
+	} else if meta.DeclType != "TEXT" || !meta.NotNull || meta.Collation != "NOCASE" || meta.Primary {
+		t.Error("Unexpected metadata", meta)
+	} else {
+		t.Log(meta)
+	}
+	if meta, err := db.ColumnMetadata("main", "test", "c"); err != nil {
+		t.Error(err)
+	} else if meta.DeclType != "" || meta.NotNull || meta.Collation != "BINARY" {
+		t.Error("Unexpected metadata", meta)
+	} else {
+		t.Log(meta)
+	}
+
+	// Missing column returns an error
+	if _, err := db.ColumnMetadata("main", "test", "d"); err == nil {
+		t.Error("Expected error for missing column")
+	}
+}
@@ -0,0 +1,437 @@
+package sqlite3
+
+import (
+	"errors"
+	"runtime/cgo"
+	"unsafe"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CGO
+
+/*
+#include <sqlite3.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+
+extern int go_vtab_connect(sqlite3*, uintptr_t, int, char**, uintptr_t*, char**);
+extern int go_vtab_bestindex(uintptr_t, sqlite3_vtab*, sqlite3_index_info*);
+extern int go_vtab_disconnect(uintptr_t);
+extern int go_vtab_open(uintptr_t, sqlite3_vtab*, uintptr_t*);
+extern int go_vtab_close(uintptr_t);
+extern int go_vtab_filter(uintptr_t, sqlite3_vtab*, int, char*, int, sqlite3_value**);
+extern int go_vtab_next(uintptr_t, sqlite3_vtab*);
+extern int go_vtab_eof(uintptr_t);
+extern int go_vtab_column(uintptr_t, sqlite3_vtab*, sqlite3_context*, int);
+extern int go_vtab_rowid(uintptr_t, sqlite3_vtab*, sqlite3_int64*);
+extern void go_module_destroy(uintptr_t);
+
+typedef struct go_vtab {
+	sqlite3_vtab base;
+	uintptr_t handle;
+} go_vtab;
+
+typedef struct go_vtab_cursor {
+	sqlite3_vtab_cursor base;
+	uintptr_t handle;
+} go_vtab_cursor;
+
+static int _go_vtab_connect(sqlite3* db, void* aux, int argc, const char* const* argv, sqlite3_vtab** ppVtab, char** pzErr) {
+	uintptr_t handle = 0;
+	int rc = go_vtab_connect(db, *(uintptr_t*)aux, argc, (char**)argv, &handle, pzErr);
+	if (rc != SQLITE_OK) {
+		return rc;
+	}
+	go_vtab* vtab = sqlite3_malloc(sizeof(go_vtab));
+	if (vtab == NULL) {
+		go_vtab_disconnect(handle);
+		return SQLITE_NOMEM;
+	}
+	memset(vtab, 0, sizeof(go_vtab));
+	vtab->handle = handle;
+	*ppVtab = &vtab->base;
+	return SQLITE_OK;
+}
+
+static int _go_vtab_bestindex(sqlite3_vtab* vtab, sqlite3_index_info* info) {
+	return go_vtab_bestindex(((go_vtab*)vtab)->handle, vtab, info);
+}
+
+static int _go_vtab_disconnect(sqlite3_vtab* vtab) {
+	int rc = go_vtab_disconnect(((go_vtab*)vtab)->handle);
+	sqlite3_free(vtab->zErrMsg);
+	sqlite3_free(vtab);
+	return rc;
+}
+
+static int _go_vtab_open(sqlite3_vtab* vtab, sqlite3_vtab_cursor** ppCursor) {
+	uintptr_t handle = 0;
+	int rc = go_vtab_open(((go_vtab*)vtab)->handle, vtab, &handle);
+	if (rc != SQLITE_OK) {
+		return rc;
+	}
+	go_vtab_cursor* cursor = sqlite3_malloc(sizeof(go_vtab_cursor));
+	if (cursor == NULL) {
+		go_vtab_close(handle);
+		return SQLITE_NOMEM;
+	}
+	memset(cursor, 0, sizeof(go_vtab_cursor));
+	cursor->handle = handle;
+	*ppCursor = &cursor->base;
+	return SQLITE_OK;
+}
+
+static int _go_vtab_close(sqlite3_vtab_cursor* cursor) {
+	int rc = go_vtab_close(((go_vtab_cursor*)cursor)->handle);
+	sqlite3_free(cursor);
+	return rc;
+}
+
+static int _go_vtab_filter(sqlite3_vtab_cursor* cursor, int idxNum, const char* idxStr, int argc, sqlite3_value** argv) {
+	return go_vtab_filter(((go_vtab_cursor*)cursor)->handle, cursor->pVtab, idxNum, (char*)idxStr, argc, argv);
+}
+
+static int _go_vtab_next(sqlite3_vtab_cursor* cursor) {
+	return go_vtab_next(((go_vtab_cursor*)cursor)->handle, cursor->pVtab);
+}
+
+static int _go_vtab_eof(sqlite3_vtab_cursor* cursor) {
+	return go_vtab_eof(((go_vtab_cursor*)cursor)->handle);
+}
+
+static int _go_vtab_column(sqlite3_vtab_cursor* cursor, sqlite3_context* ctx, int i) {
+	return go_vtab_column(((go_vtab_cursor*)cursor)->handle, cursor->pVtab, ctx, i);
+}
+
+static int _go_vtab_rowid(sqlite3_vtab_cursor* cursor, sqlite3_int64* rowid) {
+	return go_vtab_rowid(((go_vtab_cursor*)cursor)->handle, cursor->pVtab, rowid);
+}
+
+// The same function is used for xCreate and xConnect, so modules are
+// eponymous and can also be used with CREATE VIRTUAL TABLE
+static sqlite3_module _go_module = {
+	.iVersion = 0,
+	.xCreate = _go_vtab_connect,
+	.xConnect = _go_vtab_connect,
+	.xBestIndex = _go_vtab_bestindex,
+	.xDisconnect = _go_vtab_disconnect,
+	.xDestroy = _go_vtab_disconnect,
+	.xOpen = _go_vtab_open,
+	.xClose = _go_vtab_close,
+	.xFilter = _go_vtab_filter,
+	.xNext = _go_vtab_next,
+	.xEof = _go_vtab_eof,
+	.xColumn = _go_vtab_column,
+	.xRowid = _go_vtab_rowid,
+};
+
+static void _go_module_destroy(void* aux) {
+	go_module_destroy(*(uintptr_t*)aux);
+	free(aux);
+}
+
+static inline int _sqlite3_create_module_v2(sqlite3* db, const char* name, uintptr_t handle) {
+	uintptr_t* aux = malloc(sizeof(uintptr_t));
+	if (aux == NULL) {
+		return SQLITE_NOMEM;
+	}
+	*aux = handle;
+	return sqlite3_create_module_v2(db, name, &_go_module, aux, _go_module_destroy);
+}
+
+static inline void _sqlite3_vtab_seterr(sqlite3_vtab* vtab, const char* msg) {
+	sqlite3_free(vtab->zErrMsg);
+	vtab->zErrMsg = sqlite3_mprintf("%s", msg);
+}
+
+static inline void _sqlite3_seterr(char** pzErr, const char* msg) {
+	*pzErr = sqlite3_mprintf("%s", msg);
+}
+
+static inline int _sqlite3_index_constraint_column(sqlite3_index_info* info, int i) {
+	return info->aConstraint[i].iColumn;
+}
+
+static inline int _sqlite3_index_constraint_op(sqlite3_index_info* info, int i) {
+	return info->aConstraint[i].op;
+}
+
+static inline int _sqlite3_index_constraint_usable(sqlite3_index_info* info, int i) {
+	return info->aConstraint[i].usable;
+}
+
+static inline void _sqlite3_index_constraint_usage(sqlite3_index_info* info, int i, int argvIndex, int omit) {
+	info->aConstraintUsage[i].argvIndex = argvIndex;
+	info->aConstraintUsage[i].omit = omit;
+}
+
+static inline void _sqlite3_index_set_str(sqlite3_index_info* info, const char* str) {
+	if (info->needToFreeIdxStr) {
+		sqlite3_free(info->idxStr);
+	}
+	info->idxStr = sqlite3_mprintf("%s", str);
+	info->needToFreeIdxStr = 1;
+}
+*/
+import "C"
+
+///////////////////////////////////////////////////////////////////////////////
+// TYPES
+
+// Module is a virtual table module. Connect is called with the module name,
+// database name, table name and any module arguments, and returns the virtual
+// table and a CREATE TABLE statement which declares its columns.
+type Module interface {
+	Connect(args []string) (VTable, string, error)
+}
+
+// VTable is a read-only virtual table
+type VTable interface {
+	// BestIndex selects the constraints used to filter the table
+	BestIndex(*IndexInfo) error
+
+	// Open returns a cursor for reading the table
+	Open() (VCursor, error)
+
+	// Disconnect is called when the table is no longer used
+	Disconnect() error
+}
+
+// VCursor reads rows from a virtual table
+type VCursor interface {
+	// Filter starts a search with the index number and string chosen
+	// by BestIndex, and the values for the constraints used
+	Filter(idxNum int, idxStr string, args []*Value) error
+
+	// Next advances the cursor to the next row
+	Next() error
+
+	// EOF returns true when there are no more rows
+	EOF() bool
+
+	// Column sets the result for a column in the current row
+	Column(ctx *Context, i int) error
+
+	// Rowid returns the rowid of the current row
+	Rowid() (int64, error)
+
+	// Close is called when the cursor is no longer used
+	Close() error
+}
+
+type (
+	IndexInfo       C.sqlite3_index_info
+	IndexConstraint int
+)
+
+// IndexConstraintInfo describes a constraint on a column
+type IndexConstraintInfo struct {
+	Column int             // Column constrained, or -1 for the rowid
+	Op     IndexConstraint // Constraint operator
+	Usable bool            // True if the constraint can be used
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+const (
+	SQLITE_INDEX_CONSTRAINT_EQ        IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_EQ
+	SQLITE_INDEX_CONSTRAINT_GT        IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_GT
+	SQLITE_INDEX_CONSTRAINT_LE        IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_LE
+	SQLITE_INDEX_CONSTRAINT_LT        IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_LT
+	SQLITE_INDEX_CONSTRAINT_GE        IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_GE
+	SQLITE_INDEX_CONSTRAINT_MATCH     IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_MATCH
+	SQLITE_INDEX_CONSTRAINT_LIKE      IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_LIKE
+	SQLITE_INDEX_CONSTRAINT_GLOB      IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_GLOB
+	SQLITE_INDEX_CONSTRAINT_REGEXP    IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_REGEXP
+	SQLITE_INDEX_CONSTRAINT_NE        IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_NE
+	SQLITE_INDEX_CONSTRAINT_ISNOT     IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_ISNOT
+	SQLITE_INDEX_CONSTRAINT_ISNOTNULL IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_ISNOTNULL
+	SQLITE_INDEX_CONSTRAINT_ISNULL    IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_ISNULL
+	SQLITE_INDEX_CONSTRAINT_IS        IndexConstraint = C.SQLITE_INDEX_CONSTRAINT_IS
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Create a virtual table module. The module can be used as a table with
+// the same name, or with CREATE VIRTUAL TABLE
+func (c *ConnEx) CreateModule(name string, module Module) error {
+	// Convert name to C string
+	var cName *C.char
+	cName = C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	// Call create, the handle is deleted when the module is destroyed
+	handle := cgo.NewHandle(module)
+	if err := SQError(C._sqlite3_create_module_v2((*C.sqlite3)(c.Conn), cName, C.uintptr_t(handle))); err != SQLITE_OK {
+		return err
+	}
+
+	// Return success
+	return nil
+}
+
+// Constraints returns the constraints on the columns of the table
+func (info *IndexInfo) Constraints() []IndexConstraintInfo {
+	ptr := (*C.sqlite3_index_info)(info)
+	result := make([]IndexConstraintInfo, int(info.nConstraint))
+	for i := range result {
+		result[i] = IndexConstraintInfo{
+			Column: int(C._sqlite3_index_constraint_column(ptr, C.int(i))),
+			Op:     IndexConstraint(C._sqlite3_index_constraint_op(ptr, C.int(i))),
+			Usable: C._sqlite3_index_constraint_usable(ptr, C.int(i)) != 0,
+		}
+	}
+	return result
+}
+
+// SetConstraintUsage sets the position of the value for a constraint in the
+// arguments passed to Filter, starting at one. Set omit to true when the
+// constraint does not need to be checked again.
+func (info *IndexInfo) SetConstraintUsage(i, argvIndex int, omit bool) {
+	var o C.int
+	if omit {
+		o = 1
+	}
+	C._sqlite3_index_constraint_usage((*C.sqlite3_index_info)(info), C.int(i), C.int(argvIndex), o)
+}
+
+// SetIndex sets the index number and string passed to Filter
+func (info *IndexInfo) SetIndex(num int, str string) {
+	info.idxNum = C.int(num)
+	if str != "" {
+		cStr := C.CString(str)
+		defer C.free(unsafe.Pointer(cStr))
+		C._sqlite3_index_set_str((*C.sqlite3_index_info)(info), cStr)
+	}
+}
+
+// SetEstimatedCost sets the estimated cost of a search
+func (info *IndexInfo) SetEstimatedCost(cost float64) {
+	info.estimatedCost = C.double(cost)
+}
+
+// SetEstimatedRows sets the estimated number of rows returned by a search
+func (info *IndexInfo) SetEstimatedRows(rows int64) {
+	info.estimatedRows = C.sqlite3_int64(rows)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// vtabError sets the error message for a virtual table and returns the error code
+func vtabError(vtab *C.sqlite3_vtab, err error) C.int {
+	if err == nil {
+		return C.SQLITE_OK
+	}
+	var code SQError
+	if errors.As(err, &code) {
+		return C.int(code)
+	}
+	cErr := C.CString(err.Error())
+	defer C.free(unsafe.Pointer(cErr))
+	C._sqlite3_vtab_seterr(vtab, cErr)
+	return C.SQLITE_ERROR
+}
+
+//export go_vtab_connect
+func go_vtab_connect(db *C.sqlite3, module C.uintptr_t, argc C.int, argv **C.char, table *C.uintptr_t, pzErr **C.char) C.int {
+	args := make([]string, int(argc))
+	for i, arg := range unsafe.Slice(argv, int(argc)) {
+		args[i] = C.GoString(arg)
+	}
+	vtab, schema, err := cgo.Handle(module).Value().(Module).Connect(args)
+	if err == nil {
+		cSchema := C.CString(schema)
+		defer C.free(unsafe.Pointer(cSchema))
+		if err := SQError(C.sqlite3_declare_vtab(db, cSchema)); err != SQLITE_OK {
+			vtab.Disconnect()
+			return C.int(err)
+		}
+		*table = C.uintptr_t(cgo.NewHandle(vtab))
+		return C.SQLITE_OK
+	}
+	cErr := C.CString(err.Error())
+	defer C.free(unsafe.Pointer(cErr))
+	C._sqlite3_seterr(pzErr, cErr)
+	return C.SQLITE_ERROR
+}
+
+//export go_vtab_bestindex
+func go_vtab_bestindex(table C.uintptr_t, vtab *C.sqlite3_vtab, info *C.sqlite3_index_info) C.int {
+	return vtabError(vtab, cgo.Handle(table).Value().(VTable).BestIndex((*IndexInfo)(info)))
+}
+
+//export go_vtab_disconnect
+func go_vtab_disconnect(table C.uintptr_t) C.int {
+	h := cgo.Handle(table)
+	defer h.Delete()
+	if err := h.Value().(VTable).Disconnect(); err != nil {
+		return C.SQLITE_ERROR
+	}
+	return C.SQLITE_OK
+}
+
+//export go_vtab_open
+func go_vtab_open(table C.uintptr_t, vtab *C.sqlite3_vtab, cursor *C.uintptr_t) C.int {
+	c, err := cgo.Handle(table).Value().(VTable).Open()
+	if err != nil {
+		return vtabError(vtab, err)
+	}
+	*cursor = C.uintptr_t(cgo.NewHandle(c))
+	return C.SQLITE_OK
+}
+
+//export go_vtab_close
+func go_vtab_close(cursor C.uintptr_t) C.int {
+	h := cgo.Handle(cursor)
+	defer h.Delete()
+	if err := h.Value().(VCursor).Close(); err != nil {
+		return C.SQLITE_ERROR
+	}
+	return C.SQLITE_OK
+}
+
+//export go_vtab_filter
+func go_vtab_filter(cursor C.uintptr_t, vtab *C.sqlite3_vtab, idxNum C.int, idxStr *C.char, argc C.int, argv **C.sqlite3_value) C.int {
+	var str string
+	if idxStr != nil {
+		str = C.GoString(idxStr)
+	}
+	return vtabError(vtab, cgo.Handle(cursor).Value().(VCursor).Filter(int(idxNum), str, values(int(argc), argv)))
+}
+
+//export go_vtab_next
+func go_vtab_next(cursor C.uintptr_t, vtab *C.sqlite3_vtab) C.int {
+	return vtabError(vtab, cgo.Handle(cursor).Value().(VCursor).Next())
+}
+
+//export go_vtab_eof
+func go_vtab_eof(cursor C.uintptr_t) C.int {
+	if cgo.Handle(cursor).Value().(VCursor).EOF() {
+		return 1
+	}
+	return 0
+}
+
+//export go_vtab_column
+func go_vtab_column(cursor C.uintptr_t, vtab *C.sqlite3_vtab, ctx *C.sqlite3_context, i C.int) C.int {
+	return vtabError(vtab, cgo.Handle(cursor).Value().(VCursor).Column((*Context)(ctx), int(i)))
+}
+
+//export go_vtab_rowid
+func go_vtab_rowid(cursor C.uintptr_t, vtab *C.sqlite3_vtab, rowid *C.sqlite3_int64) C.int {
+	v, err := cgo.Handle(cursor).Value().(VCursor).Rowid()
+	if err != nil {
+		return vtabError(vtab, err)
+	}
+	*rowid = C.sqlite3_int64(v)
+	return C.SQLITE_OK
+}
+
+//export go_module_destroy
+func go_module_destroy(module C.uintptr_t) {
+	cgo.Handle(module).Delete()
+}
@@ -0,0 +1,148 @@
+package sqlite3_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mutablelogic/go-sqlite/sys/sqlite3"
+)
+
+func Test_Module_001(t *testing.T) {
+	tmpdir, err := os.MkdirTemp("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	db, err := sqlite3.OpenPathEx(filepath.Join(tmpdir, "test.sqlite"), sqlite3.SQLITE_OPEN_CREATE, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create a module which returns fruit
+	module := &FruitModule{fruit: []string{"apple", "banana", "cherry"}}
+	if err := db.CreateModule("fruit", module); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the module as an eponymous table, and with a virtual table
+	if err := db.Exec("CREATE VIRTUAL TABLE basket USING fruit", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"fruit", "basket"} {
+		if rows := queryModule(t, db, "SELECT name FROM "+table); len(rows) != 3 || rows[0] != "apple" || rows[2] != "cherry" {
+			t.Error("Unexpected rows:", rows)
+		}
+		if rows := queryModule(t, db, "SELECT name FROM "+table+" WHERE name='banana'"); len(rows) != 1 || rows[0] != "banana" {
+			t.Error("Unexpected rows:", rows)
+		}
+		if rows := queryModule(t, db, "SELECT name FROM "+table+" WHERE rowid=3"); len(rows) != 1 || rows[0] != "cherry" {
+			t.Error("Unexpected rows:", rows)
+		}
+	}
+	if module.filters == 0 {
+		t.Error("Expected filter to be used")
+	}
+
+	// Errors are returned from the cursor
+	if err := db.Exec("SELECT name FROM fruit WHERE name='error'", nil); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func queryModule(t *testing.T, db *sqlite3.ConnEx, q string) []string {
+	st, err := db.Prepare(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	r, err := st.Exec(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := []string{}
+	for row := r.Next(); row != nil; row = r.Next() {
+		result = append(result, row[0].(string))
+	}
+	return result
+}
+
+type FruitModule struct {
+	fruit   []string
+	filters int
+}
+
+type FruitCursor struct {
+	*FruitModule
+	name string
+	i    int
+}
+
+func (m *FruitModule) Connect(args []string) (sqlite3.VTable, string, error) {
+	return m, "CREATE TABLE x(name TEXT)", nil
+}
+
+func (m *FruitModule) BestIndex(info *sqlite3.IndexInfo) error {
+	for i, c := range info.Constraints() {
+		if c.Usable && c.Column == 0 && c.Op == sqlite3.SQLITE_INDEX_CONSTRAINT_EQ {
+			info.SetConstraintUsage(i, 1, true)
+			info.SetIndex(1, "name")
+			info.SetEstimatedCost(1)
+			return nil
+		}
+	}
+	info.SetEstimatedCost(float64(len(m.fruit)))
+	return nil
+}
+
+func (m *FruitModule) Open() (sqlite3.VCursor, error) {
+	return &FruitCursor{FruitModule: m}, nil
+}
+
+func (m *FruitModule) Disconnect() error {
+	return nil
+}
+
+func (c *FruitCursor) Filter(idxNum int, idxStr string, args []*sqlite3.Value) error {
+	c.i, c.name = 0, ""
+	if idxNum == 1 && idxStr == "name" {
+		c.filters++
+		c.name = args[0].Text()
+		if c.name == "error" {
+			return sqlite3.SQLITE_CONSTRAINT
+		}
+	}
+	c.skip()
+	return nil
+}
+
+func (c *FruitCursor) Next() error {
+	c.i++
+	c.skip()
+	return nil
+}
+
+func (c *FruitCursor) EOF() bool {
+	return c.i >= len(c.fruit)
+}
+
+func (c *FruitCursor) Column(ctx *sqlite3.Context, i int) error {
+	ctx.ResultText(c.fruit[c.i])
+	return nil
+}
+
+func (c *FruitCursor) Rowid() (int64, error) {
+	return int64(c.i + 1), nil
+}
+
+func (c *FruitCursor) Close() error {
+	return nil
+}
+
+// skip fruit which does not match the name
+func (c *FruitCursor) skip() {
+	for c.name != "" && c.i < len(c.fruit) && c.fruit[c.i] != c.name {
+		c.i++
+	}
+}
@@ -122,6 +122,9 @@ func (r *Results) ExpandedSQL() string {
 
 // Return column count
 func (r *Results) ColumnCount() int {
+	if r.st == nil {
+		return 0
+	}
 	return r.st.ColumnCount()
 }
 
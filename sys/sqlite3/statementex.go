@@ -142,6 +142,35 @@ func (s *StatementEx) Exec(n uint, v ...interface{}) (*Results, error) {
 	}
 }
 
+// Reset all prepared statements, so they are no longer busy
+func (s *StatementEx) Reset() error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	var result error
+	for _, st := range s.st {
+		if err := st.Reset(); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	// Return any errors
+	return result
+}
+
+// IsBusy returns true if any prepared statement has been stepped but
+// has not run to completion or been reset
+func (s *StatementEx) IsBusy() bool {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	for _, st := range s.st {
+		if st.IsBusy() {
+			return true
+		}
+	}
+	return false
+}
+
 // Increment adds n to the statement counter and updates the timestamp
 func (s *StatementEx) Inc(n uint64) uint64 {
 	atomic.StoreInt64(&s.ts, time.Now().UnixNano())